@@ -0,0 +1,71 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/hyprxlabs/xtask/workflows"
+	"github.com/spf13/cobra"
+)
+
+// importsCmd represents the imports command
+var importsCmd = &cobra.Command{
+	Use:   "imports",
+	Short: "Manages the remote imports pinned in xtask.lock",
+}
+
+var importsUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Downloads the remote imports again and pins them in xtask.lock",
+	Run: func(cmd *cobra.Command, args []string) {
+		loadImports(cmd, func(wf *workflows.Workflow) { wf.UpdateImports = true })
+		cmd.Println("Updated " + workflows.LockFileName)
+		os.Exit(0)
+	},
+}
+
+var importsVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies the cached remote imports against xtask.lock without downloading them",
+	Run: func(cmd *cobra.Command, args []string) {
+		loadImports(cmd, func(wf *workflows.Workflow) { wf.OfflineImports = true })
+		cmd.Println("Verified " + workflows.LockFileName)
+		os.Exit(0)
+	},
+}
+
+func loadImports(cmd *cobra.Command, configure func(wf *workflows.Workflow)) {
+	file, _ := cmd.Flags().GetString("file")
+	dir, _ := cmd.Flags().GetString("dir")
+	file, err := getFile(file, dir)
+	if err != nil {
+		cmd.PrintErrf("Error resolving file: %v\n", err)
+		os.Exit(1)
+	}
+
+	tf := types.NewXTaskfile()
+	err = tf.DecodeYAMLFile(file)
+	if err != nil {
+		cmd.PrintErrf("Error decoding xtaskfile: %v\n", err)
+		os.Exit(1)
+	}
+
+	wf := workflows.NewWorkflow()
+	wf.Context = cmd.Context()
+	configure(wf)
+
+	err = wf.Load(*tf)
+	if err != nil {
+		cmd.PrintErrf("Error loading xtaskfile: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	importsCmd.AddCommand(importsUpdateCmd)
+	importsCmd.AddCommand(importsVerifyCmd)
+	rootCmd.AddCommand(importsCmd)
+}
@@ -107,6 +107,10 @@ func init() {
 	flags := installCmd.Flags()
 	flags.StringArrayP("dotenv", "E", []string{}, "List of dotenv files to load")
 	flags.StringToStringP("env", "e", map[string]string{}, "List of environment variables to set")
+	flags.IntP("jobs", "j", 0, "Maximum number of tasks to run at the same time (default is the number of CPUs)")
+	flags.Bool("force", false, "Run tasks with sources or generates even if they are up to date")
+	flags.Bool("dry-run", false, "Print the tasks that would run without running them")
+	flags.StringP("limit", "l", "", "Only run on the hosts that match the selector, e.g. web&meta.region=eu")
 	rootCmd.AddCommand(installCmd)
 
 	// Here you will define your flags and configuration settings.
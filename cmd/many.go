@@ -64,6 +64,11 @@ tasks may result in unexpected behavior.
 		}
 
 		wf := workflows.NewWorkflow()
+		wf.Context = cmd.Context()
+		wf.Jobs, _ = flags.GetInt("jobs")
+		wf.Force, _ = flags.GetBool("force")
+		wf.DryRun, _ = flags.GetBool("dry-run")
+		wf.Limit, _ = flags.GetString("limit")
 
 		err = wf.Load(*tf)
 		if err != nil {
@@ -83,6 +88,13 @@ tasks may result in unexpected behavior.
 }
 
 func init() {
+	flags := manyCmd.Flags()
+	flags.StringArrayP("dotenv", "E", []string{}, "List of dotenv files to load")
+	flags.StringToStringP("env", "e", map[string]string{}, "List of environment variables to set")
+	flags.IntP("jobs", "j", 0, "Maximum number of tasks to run at the same time (default is the number of CPUs)")
+	flags.Bool("force", false, "Run tasks with sources or generates even if they are up to date")
+	flags.Bool("dry-run", false, "Print the tasks that would run without running them")
+	flags.StringP("limit", "l", "", "Only run on the hosts that match the selector, e.g. web&meta.region=eu")
 	rootCmd.AddCommand(manyCmd)
 
 	// Here you will define your flags and configuration settings.
@@ -4,9 +4,12 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
 	"slices"
 	"strings"
+	"syscall"
 
 	"github.com/hyprxlabs/go/env"
 	"github.com/hyprxlabs/xtask/versions"
@@ -39,6 +42,7 @@ func Execute() {
 		"down",
 		"exec",
 		"help",
+		"imports",
 		"install",
 		"many",
 		"pack",
@@ -89,7 +93,16 @@ func Execute() {
 		rootCmd.SetArgs(args[1:])
 	}
 
-	err := rootCmd.Execute()
+	// the first interrupt cancels the running tasks and lets them clean up,
+	// a second interrupt falls back to the default behavior and exits.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
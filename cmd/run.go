@@ -57,6 +57,10 @@ force all subsequent arguments to be treated as remaining arguments.`,
 		flags.StringArrayP("dotenv", "E", []string{}, "List of dotenv files to load")
 		flags.StringToStringP("env", "e", map[string]string{}, "List of environment variables to set")
 		flags.StringP("context", "c", env.Get("XTASK_CONTEXT"), "Context to use.")
+		flags.IntP("jobs", "j", 0, "Maximum number of tasks to run at the same time (default is the number of CPUs)")
+		flags.Bool("force", false, "Run tasks with sources or generates even if they are up to date")
+		flags.Bool("dry-run", false, "Print the tasks that would run without running them")
+		flags.StringP("limit", "l", "", "Only run on the hosts that match the selector, e.g. web&meta.region=eu")
 
 		targets := []string{}
 		cmdArgs := []string{}
@@ -78,7 +82,7 @@ force all subsequent arguments to be treated as remaining arguments.`,
 			if len(n) > 0 && n[0] == '-' {
 				cmdArgs = append(cmdArgs, n)
 				j := i + 1
-				if j < size && len(args[j]) > 0 && args[j][0] != '-' {
+				if j < size && len(args[j]) > 0 && args[j][0] != '-' && flagTakesValue(flags, n) {
 					cmdArgs = append(cmdArgs, args[j])
 					i++ // Skip the next argument as it's a value for the flag
 				}
@@ -137,6 +141,11 @@ force all subsequent arguments to be treated as remaining arguments.`,
 		}
 
 		wf := workflows.NewWorkflow()
+		wf.Context = cmd.Context()
+		wf.Jobs, _ = flags.GetInt("jobs")
+		wf.Force, _ = flags.GetBool("force")
+		wf.DryRun, _ = flags.GetBool("dry-run")
+		wf.Limit, _ = flags.GetString("limit")
 
 		err = wf.Load(*tf)
 		if err != nil {
@@ -144,6 +153,11 @@ force all subsequent arguments to be treated as remaining arguments.`,
 			os.Exit(1)
 		}
 
+		if help, ok := wf.TaskHelp(targets[0], remainingArgs); ok {
+			cmd.Print(help)
+			os.Exit(0)
+		}
+
 		err = wf.Run(targets, remainingArgs)
 
 		if err != nil {
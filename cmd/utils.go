@@ -5,13 +5,33 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hyprxlabs/xtask/types"
 	"github.com/hyprxlabs/xtask/workflows"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
 )
 
+// flagTakesValue reports whether the flag in arg is followed by a separate
+// value argument, i.e. it is not a boolean flag and not in the --name=value
+// form.
+func flagTakesValue(flags *pflag.FlagSet, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+
+	var flag *pflag.Flag
+	if strings.HasPrefix(arg, "--") {
+		flag = flags.Lookup(arg[2:])
+	} else if len(arg) == 2 {
+		flag = flags.ShorthandLookup(arg[1:])
+	}
+
+	return flag == nil || flag.Value.Type() != "bool"
+}
+
 func getFile(file string, dir string) (string, error) {
 
 	if file == "" && dir == "" {
@@ -221,6 +241,8 @@ func runLifecycle(target string, cmd *cobra.Command) error {
 	}
 
 	wf := workflows.NewWorkflow()
+	wf.DryRun, _ = flags.GetBool("dry-run")
+	wf.Limit, _ = flags.GetString("limit")
 
 	err = wf.Load(*tf)
 	if err != nil {
@@ -228,6 +250,8 @@ func runLifecycle(target string, cmd *cobra.Command) error {
 	}
 
 	wf.Context = cmd.Context()
+	wf.Jobs, _ = flags.GetInt("jobs")
+	wf.Force, _ = flags.GetBool("force")
 	if wf.ContextName == "" {
 		wf.ContextName = contextName
 	}
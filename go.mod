@@ -3,13 +3,19 @@ module github.com/hyprxlabs/xtask
 go 1.24.5
 
 require (
+	github.com/Masterminds/sprig v2.22.0+incompatible
 	github.com/hyprxlabs/go/cmdargs v0.1.1
 	github.com/hyprxlabs/go/dotenv v0.1.0
 	github.com/hyprxlabs/go/env v0.1.4
 	github.com/hyprxlabs/go/exec v0.1.4
+	github.com/hyprxlabs/go/secrets v0.1.0
 	github.com/melbahja/goph v1.4.0
+	github.com/pkg/sftp v1.13.9
+	github.com/rs/zerolog v1.34.0
 	github.com/spf13/cobra v1.9.1
 	github.com/spf13/pflag v1.0.7
+	github.com/stretchr/testify v1.10.0
+	github.com/wk8/go-ordered-map/v2 v2.1.8
 	golang.org/x/crypto v0.41.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -19,14 +25,15 @@ replace (
 	github.com/hyprxlabs/go/dotenv v0.1.0 => ./xvendor/dotenv
 	github.com/hyprxlabs/go/env v0.1.4 => ./xvendor/env
 	github.com/hyprxlabs/go/exec v0.1.4 => ./xvendor/exec
+	github.com/hyprxlabs/go/secrets v0.1.0 => ./xvendor/secrets
 )
 
 require (
 	github.com/Masterminds/goutils v1.1.1 // indirect
 	github.com/Masterminds/semver v1.5.0 // indirect
-	github.com/Masterminds/sprig v2.22.0+incompatible // indirect
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
 	github.com/buger/jsonparser v1.1.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/elliotchance/orderedmap/v3 v3.1.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.4.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
@@ -40,8 +47,6 @@ require (
 	github.com/mitchellh/copystructure v1.2.0 // indirect
 	github.com/mitchellh/reflectwalk v1.0.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/pkg/sftp v1.13.9 // indirect
-	github.com/rs/zerolog v1.34.0 // indirect
-	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
 )
@@ -0,0 +1,114 @@
+package paths
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Match reports whether name matches the slash separated pattern. In
+// addition to the syntax of path.Match, a `**` segment matches zero or
+// more directories.
+func Match(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// collapse repeated ** segments
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 1 {
+				return true
+			}
+
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// Glob returns the files under root that match any of the patterns. The
+// patterns are relative to root and use forward slashes on every platform.
+// A pattern that starts with `!` excludes the files it matches. The
+// returned paths are relative to root, use forward slashes and are sorted.
+func Glob(root string, patterns []string) ([]string, error) {
+	includes := []string{}
+	excludes := []string{}
+	for _, p := range patterns {
+		p = filepath.ToSlash(p)
+		if strings.HasPrefix(p, "!") {
+			excludes = append(excludes, strings.TrimPrefix(strings.TrimPrefix(p, "!"), "./"))
+			continue
+		}
+
+		includes = append(includes, strings.TrimPrefix(p, "./"))
+	}
+
+	matches := []string{}
+	if len(includes) == 0 {
+		return matches, nil
+	}
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		for _, exclude := range excludes {
+			if Match(exclude, rel) {
+				return nil
+			}
+		}
+
+		for _, include := range includes {
+			if Match(include, rel) {
+				matches = append(matches, rel)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
@@ -0,0 +1,32 @@
+package paths_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/paths"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	assert.True(t, paths.Match("**/*.go", "main.go"))
+	assert.True(t, paths.Match("**/*.go", "cmd/run/main.go"))
+	assert.True(t, paths.Match("src/**", "src/a/b.txt"))
+	assert.True(t, paths.Match("src/**/b.txt", "src/b.txt"))
+	assert.False(t, paths.Match("*.go", "cmd/main.go"))
+	assert.False(t, paths.Match("src/**/*.txt", "lib/a.txt"))
+}
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "a_test.go", "cmd/b.go", "docs/readme.md"} {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.NoError(t, os.WriteFile(file, []byte(name), 0644))
+	}
+
+	files, err := paths.Glob(root, []string{"./**/*.go", "!**/*_test.go"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.go", "cmd/b.go"}, files)
+}
@@ -106,12 +106,17 @@ func BashScript(script string) *exec.Cmd {
 func BashScriptContext(ctx context.Context, script string, args ...string) *exec.Cmd {
 	noLines := !strings.ContainsAny(script, "\n\r")
 
+	// a single line script gets the args appended, a multi-line script
+	// gets them as the positional parameters $1, $2, ...
 	if len(args) > 0 && noLines {
-		next := cmdargs.New([]string{script}).Append(args...).String()
-		script = next
+		script = script + " " + cmdargs.New(args).String()
 	}
 
 	splat := []string{"--noprofile", "--norc", "-eo", "pipefail", "-c", script}
+	if len(args) > 0 && !noLines {
+		splat = append(splat, "bash")
+		splat = append(splat, args...)
+	}
 	exe, _ := exec.Find("bash", nil)
 	if exe == "" {
 		exe = "bash"
@@ -33,15 +33,11 @@ func PwshScriptContext(ctx context.Context, script string, args ...string) *exec
 	// if script is a single line and ends with .ps1, use -File, otherwise use -Command
 	// for single line scripts, allow appending additional arguments
 	if noLines && strings.HasSuffix(strings.TrimSpace(script), ".ps1") {
-		if len(args) > 0 {
-			next := cmdargs.New([]string{script}).Append(args...).String()
-			script = next
-		}
 		splat = append(splat, "-File", strings.TrimSpace(script))
+		splat = append(splat, args...)
 	} else {
 		if noLines && len(args) > 0 {
-			next := cmdargs.New([]string{script}).Append(args...).String()
-			script = next
+			script = script + " " + cmdargs.New(args).String()
 		}
 		splat = append(splat, "-Command", script)
 	}
@@ -74,16 +70,11 @@ func PowerShellScriptContext(ctx context.Context, script string, args ...string)
 	// if script is a single line and ends with .sh, resolve it to an absolute path
 	// and for windows, convert it to a WSL path if necessary
 	if noLines && strings.HasSuffix(strings.TrimSpace(script), ".ps1") {
-		if len(args) > 0 {
-			next := cmdargs.New([]string{script}).Append(args...).String()
-			script = next
-		}
-
 		splat = append(splat, "-File", strings.TrimSpace(script))
+		splat = append(splat, args...)
 	} else {
 		if noLines && len(args) > 0 {
-			next := cmdargs.New([]string{script}).Append(args...).String()
-			script = next
+			script = script + " " + cmdargs.New(args).String()
 		}
 
 		splat = append(splat, "-Command", script)
@@ -104,8 +95,7 @@ func ShScriptContext(ctx context.Context, script string, args ...string) *exec.C
 		exe = "sh"
 	}
 	if len(args) > 0 && !strings.ContainsAny(script, "\n\r") {
-		next := cmdargs.New([]string{script}).Append(args...).String()
-		script = next
+		script = script + " " + cmdargs.New(args).String()
 	}
 
 	splat := []string{"-e", script}
@@ -173,8 +163,7 @@ func NodeScriptContext(ctx context.Context, script string, args ...string) *exec
 		trimmed := strings.TrimSpace(script)
 		if strings.HasSuffix(trimmed, ".js") || strings.HasSuffix(trimmed, ".mjs") {
 			if len(args) > 0 {
-				next := cmdargs.New([]string{trimmed}).Append(args...).String()
-				return exec.NewContext(ctx, exe, next)
+				return exec.NewContext(ctx, exe, append([]string{trimmed}, args...)...)
 			}
 
 			return exec.NewContext(ctx, exe, trimmed)
@@ -182,8 +171,7 @@ func NodeScriptContext(ctx context.Context, script string, args ...string) *exec
 
 		if strings.HasSuffix(trimmed, ".ts") {
 			if len(args) > 0 {
-				next := cmdargs.New([]string{trimmed}).Append(args...).String()
-				return exec.NewContext(ctx, exe, "--experimental-transform-types", next)
+				return exec.NewContext(ctx, exe, append([]string{"--experimental-transform-types", trimmed}, args...)...)
 			}
 
 			return exec.NewContext(ctx, exe, "--experimental-transform-types", trimmed)
@@ -191,6 +179,7 @@ func NodeScriptContext(ctx context.Context, script string, args ...string) *exec
 	}
 
 	splat := []string{"-e", script}
+	splat = append(splat, args...)
 	return exec.NewContext(ctx, exe, splat...)
 }
 
@@ -216,14 +205,15 @@ func BunScriptContext(ctx context.Context, script string, args ...string) *exec.
 		trimmed := strings.TrimSpace(script)
 		if strings.HasSuffix(trimmed, ".js") || strings.HasSuffix(trimmed, ".mjs") || strings.HasSuffix(trimmed, ".ts") {
 			if len(args) > 0 {
-				next := cmdargs.New([]string{trimmed}).Append(args...).String()
-				return exec.NewContext(ctx, exe, "run", next)
+				return exec.NewContext(ctx, exe, append([]string{"run", trimmed}, args...)...)
 			}
+
 			return exec.NewContext(ctx, exe, "run", trimmed)
 		}
 	}
 
 	splat := []string{"-e", script}
+	splat = append(splat, args...)
 	return exec.NewContext(ctx, exe, splat...)
 }
 
@@ -254,8 +244,7 @@ func PythonScriptContext(ctx context.Context, script string, args ...string) *ex
 		trimmed := strings.TrimSpace(script)
 		if strings.HasSuffix(trimmed, ".py") {
 			if len(args) > 0 {
-				next := cmdargs.New([]string{trimmed}).Append(args...).String()
-				return exec.NewContext(ctx, exe, next)
+				return exec.NewContext(ctx, exe, append([]string{trimmed}, args...)...)
 			}
 
 			return exec.NewContext(ctx, exe, trimmed)
@@ -263,6 +252,7 @@ func PythonScriptContext(ctx context.Context, script string, args ...string) *ex
 	}
 
 	splat := []string{"-c", script}
+	splat = append(splat, args...)
 	return exec.NewContext(ctx, exe, splat...)
 }
 
@@ -287,14 +277,15 @@ func RubyScriptContext(ctx context.Context, script string, args ...string) *exec
 		trimmed := strings.TrimSpace(script)
 		if strings.HasSuffix(trimmed, ".rb") {
 			if len(args) > 0 {
-				next := cmdargs.New([]string{trimmed}).Append(args...).String()
-				return exec.NewContext(ctx, exe, next)
+				return exec.NewContext(ctx, exe, append([]string{trimmed}, args...)...)
 			}
+
 			return exec.NewContext(ctx, exe, trimmed)
 		}
 	}
 
 	splat := []string{"-e", script}
+	splat = append(splat, args...)
 	return exec.NewContext(ctx, exe, splat...)
 }
 
@@ -148,11 +148,16 @@ func BashScriptContext(ctx context.Context, script string, args ...string) *exec
 		script = resolveScriptFile(script)
 	}
 
+	// a single line script gets the args appended, a multi-line script
+	// gets them as the positional parameters $1, $2, ...
 	if len(args) > 0 && noLines {
-		next := cmdargs.New([]string{script}).Append(args...).String()
-		script = next
+		script = script + " " + cmdargs.New(args).String()
 	}
 
 	splat := []string{"--noprofile", "--norc", "-eo", "pipefail", "-c", script}
+	if len(args) > 0 && !noLines {
+		splat = append(splat, "bash")
+		splat = append(splat, args...)
+	}
 	return exec.NewContext(ctx, exe, splat...)
 }
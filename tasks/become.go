@@ -0,0 +1,205 @@
+package tasks
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/hyprxlabs/go/secrets"
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+const (
+	BecomeSudo = "sudo"
+	BecomeSu   = "su"
+	BecomeDoas = "doas"
+)
+
+// becomePrompt is the prompt given to sudo so that the password is only
+// written when sudo asks for it.
+const becomePrompt = "[xtask-become-password]:"
+
+// passwordPrompt matches the password prompts of su and doas.
+var passwordPrompt = regexp.MustCompile(`(?i)password[^\n]*:\s*$`)
+
+// become holds how the script of an ssh task is run as another user.
+type become struct {
+	method   string
+	user     string
+	password string
+}
+
+// becomeFor returns the become settings of a task on a host or nil when
+// become is not enabled. The fields of the task take precedence.
+func becomeFor(task types.Task, host types.Host, e *types.Env) (*become, error) {
+	pick := func(a *string, b *string) string {
+		if a != nil && *a != "" {
+			return *a
+		}
+		if b != nil {
+			return *b
+		}
+		return ""
+	}
+
+	enabled := host.Become != nil && *host.Become
+	if task.Become != nil {
+		enabled = *task.Become
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	b := &become{
+		method: pick(task.BecomeMethod, host.BecomeMethod),
+		user:   pick(task.BecomeUser, host.BecomeUser),
+	}
+
+	if b.method == "" {
+		b.method = BecomeSudo
+	}
+
+	if b.user == "" {
+		b.user = "root"
+	}
+
+	switch b.method {
+	case BecomeSudo, BecomeSu, BecomeDoas:
+	default:
+		return nil, errors.New("Invalid become-method " + b.method + ", expected sudo, su or doas")
+	}
+
+	// the password is the name of the env var that holds the password.
+	if name := pick(task.BecomePassword, host.BecomePassword); name != "" {
+		password, ok := e.Get(name)
+		if !ok {
+			return nil, errors.New("The become-password variable " + name + " is not set")
+		}
+		b.password = password
+	}
+
+	return b, nil
+}
+
+// command wraps script so that it runs as the become user and reports
+// whether the method needs a pty to read the password. The env of the
+// task is exported by the script because the methods reset the env.
+func (b *become) command(script string, env map[string]string, keys []string) (string, bool) {
+	sb := &strings.Builder{}
+
+	// the session stdin is only used for the password.
+	sb.WriteString("exec </dev/null\n")
+	for _, key := range keys {
+		sb.WriteString("export " + key + "=" + shellQuote(env[key]) + "\n")
+	}
+	sb.WriteString(script)
+
+	quoted := shellQuote(sb.String())
+	user := shellQuote(b.user)
+
+	switch b.method {
+	case BecomeSu:
+		return "su " + user + " -c " + quoted, true
+	case BecomeDoas:
+		if b.password == "" {
+			return "doas -n -u " + user + " sh -c " + quoted, false
+		}
+		return "doas -u " + user + " sh -c " + quoted, true
+	default:
+		if b.password == "" {
+			return "sudo -n -u " + user + " -- sh -c " + quoted, false
+		}
+		return "sudo -S -p " + shellQuote(becomePrompt) + " -u " + user + " -- sh -c " + quoted, false
+	}
+}
+
+// isPrompt reports whether the last, incomplete line of output asks for
+// the password.
+func (b *become) isPrompt(line string) bool {
+	if b.method == BecomeSudo {
+		return strings.TrimSpace(line) == becomePrompt
+	}
+
+	return passwordPrompt.MatchString(line)
+}
+
+// becomeWriter writes the output of a become script. The password is
+// written to stdin when the output asks for it, the prompt is removed and
+// the password is masked in the output.
+type becomeWriter struct {
+	out    io.Writer
+	become *become
+	masker *secrets.SecretMasker
+	answer func()
+	buf    []byte
+}
+
+func newBecomeWriter(out io.Writer, b *become, answer func()) *becomeWriter {
+	masker := secrets.NewSecretMasker()
+	masker.AddValue(b.password)
+	return &becomeWriter{out: out, become: b, masker: masker, answer: answer}
+}
+
+func (w *becomeWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		if _, err := io.WriteString(w.out, w.masker.Mask(string(w.buf[:i+1]))); err != nil {
+			return len(p), err
+		}
+
+		w.buf = w.buf[i+1:]
+	}
+
+	if w.become.password != "" && len(w.buf) > 0 && w.become.isPrompt(string(w.buf)) {
+		w.buf = nil
+		w.answer()
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the remaining output that did not end with a new line.
+func (w *becomeWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	line := w.masker.Mask(string(w.buf))
+	w.buf = nil
+	_, err := io.WriteString(w.out, line)
+	return err
+}
+
+// becomeAnswer returns a func that writes the password to stdin once. A
+// second prompt means the password was rejected, so stdin is closed to
+// stop the method from waiting for another password.
+func becomeAnswer(stdin io.WriteCloser, password string) func() {
+	var mu sync.Mutex
+	answered := false
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if answered {
+			stdin.Close()
+			return
+		}
+
+		answered = true
+		io.WriteString(stdin, password+"\n")
+	}
+}
+
+// shellQuote quotes s for a posix shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,135 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBecomeFor(t *testing.T) {
+	e := types.NewEnv()
+	e.Set("SUDO_PASSWORD", "hunter2")
+
+	yes := true
+	no := false
+	method := "doas"
+	user := "app"
+	password := "SUDO_PASSWORD"
+
+	b, err := becomeFor(types.Task{}, types.Host{}, e)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	// the task overrides the host.
+	b, err = becomeFor(types.Task{Become: &no}, types.Host{Become: &yes}, e)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	b, err = becomeFor(types.Task{BecomeUser: &user}, types.Host{Become: &yes, BecomeMethod: &method, BecomePassword: &password}, e)
+	assert.NoError(t, err)
+	assert.Equal(t, &become{method: "doas", user: "app", password: "hunter2"}, b)
+
+	b, err = becomeFor(types.Task{Become: &yes}, types.Host{}, e)
+	assert.NoError(t, err)
+	assert.Equal(t, &become{method: "sudo", user: "root"}, b)
+
+	missing := "MISSING"
+	_, err = becomeFor(types.Task{Become: &yes, BecomePassword: &missing}, types.Host{}, e)
+	assert.Error(t, err)
+
+	invalid := "runas"
+	_, err = becomeFor(types.Task{Become: &yes, BecomeMethod: &invalid}, types.Host{}, e)
+	assert.Error(t, err)
+}
+
+func TestBecomeCommand(t *testing.T) {
+	cmd, pty := (&become{method: "sudo", user: "root"}).command("id -u", nil, nil)
+	assert.False(t, pty)
+	assert.Equal(t, "sudo -n -u 'root' -- sh -c 'exec </dev/null\nid -u'", cmd)
+
+	cmd, pty = (&become{method: "sudo", user: "root", password: "pw"}).command("echo $A", map[string]string{"A": "it's"}, []string{"A"})
+	assert.False(t, pty)
+	assert.True(t, strings.HasPrefix(cmd, "sudo -S -p '"+becomePrompt+"' -u 'root' -- sh -c "))
+	assert.Contains(t, cmd, `export A='"'"'it'"'"'"'"'"'"'"'"'s'"'"'`)
+
+	_, pty = (&become{method: "su", user: "app", password: "pw"}).command("id", nil, nil)
+	assert.True(t, pty)
+}
+
+func TestBecomeWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	answers := 0
+	b := &become{method: "su", user: "root", password: "hunter2"}
+	w := newBecomeWriter(out, b, func() { answers++ })
+
+	w.Write([]byte("Password: "))
+	assert.Equal(t, 1, answers)
+
+	w.Write([]byte("\r\nthe password is hunter2\nno newline"))
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "\r\nthe password is ****\nno newline", out.String())
+}
+
+func TestSSHBecomeSudo(t *testing.T) {
+	// a fake sudo that asks for the password with the given prompt.
+	bin := t.TempDir()
+	sudo := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -S) shift ;;
+    -n) echo "sudo: a password is required" >&2; exit 1 ;;
+    -p) prompt="$2"; shift 2 ;;
+    -u) user="$2"; shift 2 ;;
+    --) shift; break ;;
+    *) break ;;
+  esac
+done
+printf '%s' "$prompt" >&2
+read -r pw
+if [ "$pw" != "hunter2" ]; then echo "sudo: incorrect password" >&2; exit 1; fi
+BECOME_USER="$user" exec "$@"
+`
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), []byte(sudo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	server := newTestSSHServer(t, nil)
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Env.Set("SUDO_PASSWORD", "hunter2")
+	ctx.Data.Env.Set("GREETING", "hi")
+	ctx.Task.Env = *types.NewEnv()
+	ctx.Task.Env.Set("GREETING", "hi")
+	ctx.Data.Run = "echo \"$BECOME_USER says $GREETING\"\necho the password is hunter2"
+
+	yes := true
+	password := "SUDO_PASSWORD"
+	ctx.Task.Become = &yes
+	ctx.Task.BecomePassword = &password
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code, err := runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, stderr)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "root says hi\nthe password is ****\n", stdout.String())
+	assert.Equal(t, "", stderr.String())
+
+	ctx.Data.Env.Set("SUDO_PASSWORD", "wrong")
+	stderr.Reset()
+	code, err = runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, stderr)
+	assert.Error(t, err)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "sudo: incorrect password\n", stderr.String())
+
+	// without a password sudo must not prompt.
+	ctx.Task.BecomePassword = nil
+	code, _ = runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, stderr)
+	assert.Equal(t, 1, code)
+}
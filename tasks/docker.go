@@ -1,11 +1,266 @@
 package tasks
 
 import (
-	"errors"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/hyprxlabs/xtask/errors"
 )
 
+// dockerWorkspace is the path XTASK_DIR is mounted at in the container.
+const dockerWorkspace = "/workspace"
+
+// hostEnvVars are not passed to the container as they describe the host.
+var hostEnvVars = []string{
+	"PATH", "HOME", "USER", "USERNAME", "LOGNAME", "SHELL", "PWD", "OLDPWD",
+	"HOSTNAME", "TMPDIR", "TEMP", "TMP", "SHLVL", "_", "TERM_PROGRAM",
+	"TERM_PROGRAM_VERSION", "SSH_AUTH_SOCK", "SSH_AGENT_PID", "DISPLAY",
+	"PATHEXT", "COMSPEC", "SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "APPDATA",
+	"LOCALAPPDATA", "PROGRAMDATA", "PROGRAMFILES", "USERPROFILE", "PSMODULEPATH",
+}
+
+var containerNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func init() {
+	exec.Register("docker", &exec.Executable{
+		Name:     "docker",
+		Variable: "XTASK_DOCKER_EXE",
+		Linux:    []string{"docker"},
+		Windows:  []string{"docker"},
+	})
+
+	exec.Register("podman", &exec.Executable{
+		Name:     "podman",
+		Variable: "XTASK_PODMAN_EXE",
+		Linux:    []string{"podman"},
+		Windows:  []string{"podman"},
+	})
+}
+
 func runDocker(ctx TaskContext) *TaskResult {
-	// Placeholder for running a Docker command
-	// This would typically involve executing the command in a Docker container
-	return NewTaskResult().Fail(errors.New("docker task not implemented"))
+	res := NewTaskResult()
+
+	image := strings.TrimPrefix(ctx.Data.Uses, "docker://")
+	if image == "docker" || image == "" {
+		image = withString(ctx.Data.With, "image")
+	}
+
+	if image == "" {
+		return res.Fail(errors.New("No image specified for docker task " + ctx.Data.Id + ", use docker://image:tag or with.image"))
+	}
+
+	exe := ""
+	withTaskEnv(&ctx.Data.Env, func() {
+		for _, name := range []string{"docker", "podman"} {
+			if p, err := exec.Find(name, nil); err == nil && p != "" {
+				exe = p
+				return
+			}
+		}
+	})
+
+	if exe == "" {
+		return res.Fail(errors.New("docker or podman not found, set XTASK_DOCKER_EXE to the path of the executable"))
+	}
+
+	name := "xtask-" + strings.Trim(containerNameInvalid.ReplaceAllString(ctx.Data.Id, "-"), "-.") + "-" + randomSuffix()
+	args, err := dockerRunArgs(ctx, image, name)
+	if err != nil {
+		return res.Fail(err)
+	}
+
+	cmd := exec.NewContext(ctx.Context, exe, args...)
+	if ctx.Data.Cwd != "" {
+		cmd.Dir = ctx.Data.Cwd
+	}
+
+	// the values are passed through the env of the docker cli so that they
+	// do not show up in the process list.
+	cmd.WithEnvMap(ctx.Data.Env.ToMap())
+
+	// killing the docker cli does not stop the container, so the container
+	// is stopped first.
+	cmd.Cancel = func() error {
+		stopCtx, cancel := context.WithTimeout(context.Background(), killGracePeriod+5*time.Second)
+		defer cancel()
+
+		grace := strconv.Itoa(int(killGracePeriod / time.Second))
+		stop := exec.NewContext(stopCtx, exe, "stop", "--time", grace, name)
+		stop.WithEnvMap(ctx.Data.Env.ToMap())
+		stop.Quiet()
+
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = killGracePeriod + 10*time.Second
+
+	res.Start()
+	o, err := cmd.Run()
+	if ctxErr := ctx.Context.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return res.Cancel("Task " + ctx.Task.Id + " timed out after " + ctx.Data.Timeout.String())
+		}
+
+		return res.Cancel("Task " + ctx.Task.Id + " cancelled")
+	}
+
+	if err != nil {
+		return res.Fail(err)
+	}
+
+	if o.Code != 0 {
+		return res.Fail(errors.New("Task " + ctx.Task.Id + " failed with exit code " + strconv.Itoa(o.Code)))
+	}
+
+	return res.Ok()
+}
+
+// dockerRunArgs returns the arguments for `docker run` of the task.
+func dockerRunArgs(ctx TaskContext, image string, name string) ([]string, error) {
+	with := ctx.Data.With
+	args := []string{"run", "--rm", "-i", "--name", name}
+
+	workspace := ctx.Data.Env.GetString("XTASK_DIR")
+	if workspace == "" {
+		workspace = ctx.Data.Cwd
+	}
+
+	workdir := withString(with, "workdir")
+	if workspace != "" {
+		args = append(args, "-v", workspace+":"+dockerWorkspace)
+
+		if workdir == "" {
+			workdir = dockerWorkspace
+			if ctx.Data.Cwd != "" {
+				rel, err := filepath.Rel(workspace, ctx.Data.Cwd)
+				if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+					workdir = path.Join(dockerWorkspace, filepath.ToSlash(rel))
+				}
+			}
+		}
+	}
+
+	if workdir != "" {
+		args = append(args, "-w", workdir)
+	}
+
+	for _, volume := range withStrings(with, "volumes") {
+		// bind mounts require an absolute path on the host.
+		src, rest, ok := splitVolume(volume)
+		if ok && (strings.HasPrefix(src, ".") || strings.HasPrefix(src, "~")) {
+			if strings.HasPrefix(src, "~") {
+				src = ctx.Data.Env.GetString("HOME") + src[1:]
+			} else if ctx.Data.Cwd != "" {
+				src = filepath.Join(ctx.Data.Cwd, src)
+			}
+
+			volume = src + ":" + rest
+		}
+
+		args = append(args, "-v", volume)
+	}
+
+	for _, port := range withStrings(with, "ports") {
+		args = append(args, "-p", port)
+	}
+
+	if user := withString(with, "user"); user != "" {
+		args = append(args, "--user", user)
+	}
+
+	if network := withString(with, "network"); network != "" {
+		args = append(args, "--network", network)
+	}
+
+	if pull, ok := withBool(with, "pull"); ok {
+		if pull {
+			args = append(args, "--pull", "always")
+		}
+	} else if pull := withString(with, "pull"); pull != "" {
+		args = append(args, "--pull", pull)
+	}
+
+	entrypoint := withString(with, "entrypoint")
+	if entrypoint != "" {
+		args = append(args, "--entrypoint", entrypoint)
+	}
+
+	keys := ctx.Data.Env.Keys()
+	slices.Sort(keys)
+	for _, key := range keys {
+		if isHostEnvVar(key) {
+			continue
+		}
+
+		args = append(args, "-e", key)
+	}
+
+	args = append(args, image)
+
+	if ctx.Data.Run != "" {
+		if entrypoint != "" {
+			// the entrypoint receives the script as its argument.
+			args = append(args, ctx.Data.Run)
+		} else {
+			shell := withString(with, "shell")
+			if shell == "" {
+				shell = "sh"
+			}
+
+			// the task args are available to the script as $1, $2, ...
+			args = append(args, shell, "-c", ctx.Data.Run, shell)
+		}
+	}
+
+	args = append(args, ctx.Args...)
+	return args, nil
+}
+
+// splitVolume splits a volume into the source and the rest, taking care of
+// windows drive letters in the source.
+func splitVolume(volume string) (string, string, bool) {
+	start := 0
+	if len(volume) > 2 && volume[1] == ':' && (volume[2] == '\\' || volume[2] == '/') {
+		start = 2
+	}
+
+	i := strings.Index(volume[start:], ":")
+	if i < 0 {
+		return "", "", false
+	}
+
+	return volume[:start+i], volume[start+i+1:], true
+}
+
+func isHostEnvVar(key string) bool {
+	if strings.HasPrefix(key, "XTASK_") && key != "XTASK_CONTEXT" {
+		return true
+	}
+
+	if strings.HasPrefix(key, "LD_") || strings.HasPrefix(key, "DYLD_") || strings.HasPrefix(key, "XDG_") {
+		return true
+	}
+
+	for _, name := range hostEnvVars {
+		if key == name || (runtime.GOOS == "windows" && strings.EqualFold(key, name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
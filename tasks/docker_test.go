@@ -0,0 +1,111 @@
+package tasks_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFakeDocker writes a docker executable that records its arguments,
+// one per line, to a log file and then runs script.
+func writeFakeDocker(t *testing.T, script string) (string, string) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "docker.log")
+	exe := filepath.Join(dir, "docker")
+	content := "#!/bin/sh\nprintf '%s\\n' \"$@\" >> " + log + "\necho --- >> " + log + "\n" + script
+	assert.NoError(t, os.WriteFile(exe, []byte(content), 0755))
+	return exe, log
+}
+
+func TestRunDocker(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake docker is a shell script")
+	}
+
+	exe, log := writeFakeDocker(t, "exit 0\n")
+	workspace := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(workspace, "web"), 0755))
+
+	e := types.NewEnv()
+	e.Set("XTASK_DOCKER_EXE", exe)
+	e.Set("XTASK_DIR", workspace)
+	e.Set("PATH", os.Getenv("PATH"))
+	e.Set("APP_ENV", "test")
+
+	ctx := tasks.TaskContext{Context: context.Background(), Args: []string{"--verbose"}}
+	ctx.Task.Id = "build"
+	ctx.Data = tasks.TaskData{
+		Id:   "build",
+		Uses: "docker://node:lts",
+		Run:  "npm ci",
+		Env:  *e,
+		Cwd:  filepath.Join(workspace, "web"),
+		With: map[string]interface{}{
+			"volumes": []interface{}{"./cache:/root/.npm"},
+			"ports":   []interface{}{"8080:80"},
+			"user":    "1000",
+			"network": "host",
+			"pull":    true,
+			"shell":   "bash",
+		},
+	}
+
+	res := tasks.Run(ctx)
+	assert.NoError(t, res.Err)
+	assert.Equal(t, statuses.Ok, res.Status)
+	if res.Status != statuses.Ok {
+		return
+	}
+
+	bytes, err := os.ReadFile(log)
+	assert.NoError(t, err)
+	args := strings.Split(strings.TrimSuffix(string(bytes), "\n---\n"), "\n")
+
+	assert.Equal(t, []string{"run", "--rm", "-i", "--name"}, args[:4])
+	assert.True(t, strings.HasPrefix(args[4], "xtask-build-"))
+	assert.Contains(t, strings.Join(args, " "), "-v "+workspace+":/workspace -w /workspace/web")
+	assert.Contains(t, strings.Join(args, " "), "-v "+filepath.Join(workspace, "web", "cache")+":/root/.npm")
+	assert.Contains(t, strings.Join(args, " "), "-p 8080:80 --user 1000 --network host --pull always")
+	assert.Contains(t, args, "APP_ENV")
+	assert.NotContains(t, args, "PATH")
+	assert.NotContains(t, args, "XTASK_DIR")
+	assert.Equal(t, []string{"node:lts", "bash", "-c", "npm ci", "bash", "--verbose"}, args[len(args)-6:])
+}
+
+func TestRunDockerCancelStopsContainer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake docker is a shell script")
+	}
+
+	exe, log := writeFakeDocker(t, "if [ \"$1\" = run ]; then exec sleep 10; fi\n")
+
+	e := types.NewEnv()
+	e.Set("XTASK_DOCKER_EXE", exe)
+	e.Set("PATH", os.Getenv("PATH"))
+
+	c, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	ctx := tasks.TaskContext{Context: c}
+	ctx.Task.Id = "serve"
+	ctx.Data = tasks.TaskData{Id: "serve", Uses: "docker://nginx", Env: *e, Cwd: t.TempDir(), Timeout: 200 * time.Millisecond}
+
+	start := time.Now()
+	res := tasks.Run(ctx)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, statuses.Cancelled, res.Status)
+	assert.Contains(t, res.Message, "timed out")
+
+	bytes, err := os.ReadFile(log)
+	assert.NoError(t, err)
+	assert.Contains(t, string(bytes), "---\nstop\n")
+}
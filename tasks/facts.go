@@ -0,0 +1,199 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/types"
+	"gopkg.in/yaml.v3"
+)
+
+// factsTTL is how long the gathered facts of a host are cached.
+const factsTTL = 24 * time.Hour
+
+// factsScript prints the kernel name, machine and release followed by
+// /etc/os-release, or by sw_vers on macOS.
+const factsScript = "uname -s; uname -m; uname -r; cat /etc/os-release 2>/dev/null || sw_vers 2>/dev/null || true"
+
+// GatherFacts detects the os of host over ssh. The facts are cached in
+// XTASK_STATE_HOME/facts so that the host is only asked once a day.
+func GatherFacts(ctx TaskContext, alias string, host types.Host) (*types.OS, error) {
+	file := factsFile(host, &ctx.Data.Env)
+	if facts := readFacts(file); facts != nil {
+		return facts, nil
+	}
+
+	client, release, err := connectSSH(ctx, alias, host)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return nil, errors.New("Failed to create SSH session: " + err.Error())
+	}
+	defer sess.Close()
+
+	out, err := sess.Output(factsScript)
+	if err != nil {
+		return nil, errors.New("Failed to gather facts of " + host.Host + ": " + err.Error())
+	}
+
+	facts := parseFacts(string(out))
+	if facts.Platform == "" {
+		return nil, errors.New("Failed to gather facts of " + host.Host + ": uname did not print the platform")
+	}
+
+	if file != "" {
+		if data, err := yaml.Marshal(facts); err == nil {
+			os.MkdirAll(filepath.Dir(file), 0755)
+			os.WriteFile(file, data, 0644)
+		}
+	}
+
+	return facts, nil
+}
+
+// factsFile returns the cache file of the facts of host or an empty
+// string when XTASK_STATE_HOME is not set.
+func factsFile(host types.Host, e *types.Env) string {
+	stateHome := e.GetString("XTASK_STATE_HOME")
+	if stateHome == "" {
+		return ""
+	}
+
+	name := host.Host
+	if host.User != nil && *host.User != "" {
+		name = *host.User + "@" + name
+	}
+
+	if host.Port != nil {
+		name += "-" + strconv.Itoa(*host.Port)
+	}
+
+	return filepath.Join(stateHome, "facts", sanitizeName(name, "_")+".yaml")
+}
+
+func readFacts(file string) *types.OS {
+	if file == "" {
+		return nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil || time.Since(info.ModTime()) > factsTTL {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	facts := &types.OS{}
+	if err := yaml.Unmarshal(data, facts); err != nil || facts.Platform == "" {
+		return nil
+	}
+
+	return facts
+}
+
+// parseFacts reads the output of factsScript. The arch uses the names of
+// GOARCH and the family is the first ID_LIKE of os-release, e.g. debian
+// for ubuntu, or the ID when the distro is not like another. Like keeps
+// all of ID_LIKE.
+func parseFacts(out string) *types.OS {
+	lines := strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n")
+	for len(lines) < 3 {
+		lines = append(lines, "")
+	}
+
+	facts := &types.OS{
+		Platform:     strings.ToLower(strings.TrimSpace(lines[0])),
+		Arch:         normalizeArch(strings.TrimSpace(lines[1])),
+		BuildVersion: strings.TrimSpace(lines[2]),
+	}
+
+	release := map[string]string{}
+	for _, line := range lines[3:] {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			release[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		} else if key, value, ok := strings.Cut(line, ":"); ok {
+			release[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	if id := release["ID"]; id != "" {
+		facts.Variant = id
+		facts.Family = id
+		if like := strings.Fields(release["ID_LIKE"]); len(like) > 0 {
+			facts.Family = like[0]
+			facts.Like = like
+		}
+		facts.Version = release["VERSION_ID"]
+		facts.Codename = release["VERSION_CODENAME"]
+	} else if version := release["ProductVersion"]; version != "" {
+		facts.Variant = "macos"
+		facts.Version = version
+	}
+
+	if facts.Family == "" {
+		facts.Family = facts.Platform
+	}
+
+	return facts
+}
+
+func normalizeArch(arch string) string {
+	switch strings.ToLower(arch) {
+	case "x86_64", "amd64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "i386", "i686", "x86":
+		return "386"
+	case "armv7l", "armv6l":
+		return "arm"
+	}
+
+	return strings.ToLower(arch)
+}
+
+// HostData returns the fields of a host used by templates, e.g. .host.os
+// in the if of a task. The os is empty when it is not known.
+func HostData(alias string, host types.Host) map[string]interface{} {
+	data := map[string]interface{}{
+		"alias":  alias,
+		"host":   host.Host,
+		"groups": host.Groups,
+		"meta":   host.Meta,
+	}
+
+	if host.User != nil {
+		data["user"] = *host.User
+	}
+
+	if host.Port != nil {
+		data["port"] = *host.Port
+	}
+
+	osData := map[string]interface{}{}
+	if host.OS != nil {
+		osData["platform"] = host.OS.Platform
+		osData["arch"] = host.OS.Arch
+		osData["variant"] = host.OS.Variant
+		osData["family"] = host.OS.Family
+		osData["like"] = host.OS.Like
+		osData["codename"] = host.OS.Codename
+		osData["version"] = host.OS.Version
+		osData["build_version"] = host.OS.BuildVersion
+	}
+	data["os"] = osData
+
+	return data
+}
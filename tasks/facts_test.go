@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFacts(t *testing.T) {
+	out := "Linux\naarch64\n6.8.0-45-generic\n" +
+		"PRETTY_NAME=\"Ubuntu 24.04.1 LTS\"\nID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"24.04\"\nVERSION_CODENAME=noble\n"
+
+	facts := parseFacts(out)
+	assert.Equal(t, "linux", facts.Platform)
+	assert.Equal(t, "arm64", facts.Arch)
+	assert.Equal(t, "ubuntu", facts.Variant)
+	assert.Equal(t, "debian", facts.Family)
+	assert.Equal(t, "24.04", facts.Version)
+	assert.Equal(t, "noble", facts.Codename)
+	assert.Equal(t, "6.8.0-45-generic", facts.BuildVersion)
+
+	// mint is like ubuntu and debian, rhel is only like fedora.
+	facts = parseFacts("Linux\nx86_64\n6.8.0-45-generic\n" +
+		"ID=linuxmint\nID_LIKE=\"ubuntu debian\"\nVERSION_ID=\"22\"\nVERSION_CODENAME=wilma\n")
+	assert.Equal(t, "linuxmint", facts.Variant)
+	assert.Equal(t, []string{"ubuntu", "debian"}, facts.Like)
+	assert.True(t, facts.Matches("debian"))
+	assert.True(t, facts.Matches("ubuntu"))
+	assert.False(t, facts.Matches("fedora"))
+
+	facts = parseFacts("Linux\nx86_64\n5.14.0-427.el9.x86_64\n" +
+		"ID=\"rhel\"\nID_LIKE=\"fedora\"\nVERSION_ID=\"9.4\"\n")
+	assert.Equal(t, "rhel", facts.Variant)
+	assert.Equal(t, []string{"fedora"}, facts.Like)
+	assert.True(t, facts.Matches("rhel"))
+	assert.True(t, facts.Matches("fedora"))
+	assert.False(t, facts.Matches("debian"))
+
+	facts = parseFacts("Darwin\nx86_64\n23.6.0\nProductName:\t\tmacOS\nProductVersion:\t\t14.6.1\n")
+	assert.Equal(t, "darwin", facts.Platform)
+	assert.Equal(t, "amd64", facts.Arch)
+	assert.Equal(t, "macos", facts.Variant)
+	assert.Equal(t, "darwin", facts.Family)
+	assert.Equal(t, "14.6.1", facts.Version)
+}
+
+func TestGatherFactsIsCached(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Env.Set("XTASK_STATE_HOME", t.TempDir())
+
+	facts, err := GatherFacts(ctx, "app", ctx.Data.Hosts["app"])
+	assert.NoError(t, err)
+	assert.Equal(t, runtime.GOOS, facts.Platform)
+	assert.Equal(t, runtime.GOARCH, facts.Arch)
+	assert.Equal(t, 1, server.connections())
+
+	cached, err := GatherFacts(ctx, "app", ctx.Data.Hosts["app"])
+	assert.NoError(t, err)
+	assert.Equal(t, facts, cached)
+	assert.Equal(t, 1, server.connections())
+}
@@ -0,0 +1,213 @@
+package tasks
+
+import (
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// hostTarget is a host that a task runs on.
+type hostTarget struct {
+	alias string
+	host  types.Host
+}
+
+// hostRun is the result of running a task on a single host.
+type hostRun struct {
+	alias    string
+	status   string
+	code     int
+	err      error
+	duration time.Duration
+}
+
+// hostFunc runs a task on a single host and returns the exit code of the
+// remote command, or -1 when there is none.
+type hostFunc func(ctx context.Context, target hostTarget, stdout io.Writer, stderr io.Writer) (int, error)
+
+// hostTargets returns the hosts of the task sorted by alias.
+func hostTargets(hosts types.Hosts) []hostTarget {
+	targets := []hostTarget{}
+	for alias, host := range hosts {
+		targets = append(targets, hostTarget{alias: alias, host: host})
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].alias < targets[j].alias
+	})
+
+	return targets
+}
+
+// runOnHosts runs fn for each target. By default the targets run one after
+// another and the first failure stops the remaining targets from starting.
+//
+// The with section of the task changes this:
+//
+//	parallel: true      run the targets at the same time
+//	max-parallel: 5     limit the number of targets that run at the same time
+//	fail-fast: false    run all targets even when some of them fail
+//
+// With more than one target, every line of output is prefixed with the
+// alias of the host and a summary of the results is printed at the end.
+func runOnHosts(ctx TaskContext, targets []hostTarget, fn hostFunc) *TaskResult {
+	res := NewTaskResult()
+	with := ctx.Data.With
+
+	jobs := 1
+	if parallel, ok := withBool(with, "parallel"); ok && parallel {
+		jobs = len(targets)
+	} else if n, err := strconv.Atoi(withString(with, "parallel")); err == nil && n > 0 {
+		jobs = n
+	}
+
+	if n, err := strconv.Atoi(withString(with, "max-parallel")); err == nil && n > 0 {
+		if jobs == 1 {
+			jobs = len(targets)
+		}
+
+		if n < jobs {
+			jobs = n
+		}
+	}
+
+	failFast := true
+	if v, ok := withBool(with, "fail-fast"); ok {
+		failFast = v
+	}
+
+	multi := len(targets) > 1
+	color := multi && ctx.Data.Env.GetString("NO_COLOR") == ""
+	width := 0
+	for _, t := range targets {
+		width = max(width, len(t.alias))
+	}
+
+	var outMu sync.Mutex
+	var mu sync.Mutex
+	failed := false
+	runs := make([]hostRun, len(targets))
+
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+
+	res.Start()
+	for i, target := range targets {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := failed && failFast
+		mu.Unlock()
+
+		if stop || ctx.Context.Err() != nil {
+			<-sem
+			runs[i] = hostRun{alias: target.alias, status: "skipped", code: -1}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target hostTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var stdout io.Writer = os.Stdout
+			var stderr io.Writer = os.Stderr
+			if multi {
+				prefix := "[" + target.alias + "]" + strings.Repeat(" ", width-len(target.alias)) + " "
+				if color {
+					prefix = prefixColors[i%len(prefixColors)] + prefix + "\x1b[39m"
+				}
+
+				out := newPrefixWriter(&outMu, os.Stdout, prefix)
+				errOut := newPrefixWriter(&outMu, os.Stderr, prefix)
+				defer out.Flush()
+				defer errOut.Flush()
+				stdout = out
+				stderr = errOut
+			}
+
+			start := time.Now()
+			code, err := fn(ctx.Context, target, stdout, stderr)
+			run := hostRun{alias: target.alias, status: "ok", code: code, err: err, duration: time.Since(start)}
+			if err != nil {
+				run.status = "error"
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					run.status = "cancelled"
+				}
+
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+
+			runs[i] = run
+		}(i, target)
+	}
+
+	wg.Wait()
+
+	if multi {
+		printHostSummary(runs)
+	}
+
+	if ctxErr := ctx.Context.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return res.Cancel("Task " + ctx.Task.Id + " timed out after " + ctx.Data.Timeout.String())
+		}
+
+		return res.Cancel("Task " + ctx.Task.Id + " cancelled")
+	}
+
+	errs := []string{}
+	for _, run := range runs {
+		if run.err != nil {
+			errs = append(errs, run.alias+": "+run.err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return res.Fail(errors.New(strings.Join(errs, "; ")))
+	}
+
+	return res.Ok()
+}
+
+func printHostSummary(runs []hostRun) {
+	width := len("HOST")
+	for _, run := range runs {
+		width = max(width, len(run.alias))
+	}
+
+	pad := func(s string, n int) string {
+		if len(s) >= n {
+			return s
+		}
+		return s + strings.Repeat(" ", n-len(s))
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("\n" + pad("HOST", width) + "  " + pad("STATUS", 9) + "  " + pad("EXIT", 4) + "  DURATION\n")
+	for _, run := range runs {
+		code := "-"
+		if run.code >= 0 {
+			code = strconv.Itoa(run.code)
+		}
+
+		duration := "-"
+		if run.status != "skipped" {
+			duration = run.duration.Round(time.Millisecond).String()
+		}
+
+		sb.WriteString(pad(run.alias, width) + "  " + pad(run.status, 9) + "  " + pad(code, 4) + "  " + duration + "\n")
+	}
+
+	os.Stdout.WriteString(sb.String())
+}
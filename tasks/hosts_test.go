@@ -0,0 +1,112 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHostsContext(with map[string]interface{}) TaskContext {
+	ctx := TaskContext{Context: context.Background()}
+	ctx.Task.Id = "deploy"
+	ctx.Data.Env = *types.NewEnv()
+	ctx.Data.Env.Set("NO_COLOR", "1")
+	ctx.Data.With = with
+	return ctx
+}
+
+func newHostTargets(aliases ...string) []hostTarget {
+	targets := []hostTarget{}
+	for _, alias := range aliases {
+		targets = append(targets, hostTarget{alias: alias, host: types.Host{Host: alias + ".example.com"}})
+	}
+	return targets
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var mu sync.Mutex
+	out := &bytes.Buffer{}
+	w := newPrefixWriter(&mu, out, "[web] ")
+
+	io.WriteString(w, "one\ntw")
+	assert.Equal(t, "[web] one\n", out.String())
+
+	io.WriteString(w, "o\nthree")
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "[web] one\n[web] two\n[web] three\n", out.String())
+}
+
+func TestHostTargetsSortedByAlias(t *testing.T) {
+	targets := hostTargets(types.Hosts{
+		"web2": types.Host{Host: "10.0.0.2"},
+		"db":   types.Host{Host: "10.0.0.3"},
+		"web1": types.Host{Host: "10.0.0.1"},
+	})
+
+	aliases := []string{}
+	for _, target := range targets {
+		aliases = append(aliases, target.alias)
+	}
+	assert.Equal(t, []string{"db", "web1", "web2"}, aliases)
+}
+
+func TestRunOnHostsFailFast(t *testing.T) {
+	ran := []string{}
+	res := runOnHosts(newHostsContext(nil), newHostTargets("a", "b", "c"), func(ctx context.Context, target hostTarget, stdout io.Writer, stderr io.Writer) (int, error) {
+		ran = append(ran, target.alias)
+		if target.alias == "b" {
+			return 1, errors.New("exit status 1")
+		}
+		return 0, nil
+	})
+
+	assert.Equal(t, statuses.Error, res.Status)
+	assert.EqualError(t, res.Err, "b: exit status 1")
+	assert.Equal(t, []string{"a", "b"}, ran)
+}
+
+func TestRunOnHostsWithoutFailFast(t *testing.T) {
+	var ran int32
+	ctx := newHostsContext(map[string]interface{}{"fail-fast": false})
+	res := runOnHosts(ctx, newHostTargets("a", "b", "c"), func(ctx context.Context, target hostTarget, stdout io.Writer, stderr io.Writer) (int, error) {
+		atomic.AddInt32(&ran, 1)
+		if target.alias != "b" {
+			return 2, errors.New("exit status 2")
+		}
+		return 0, nil
+	})
+
+	assert.Equal(t, statuses.Error, res.Status)
+	assert.EqualError(t, res.Err, "a: exit status 2; c: exit status 2")
+	assert.Equal(t, int32(3), ran)
+}
+
+func TestRunOnHostsMaxParallel(t *testing.T) {
+	var running, peak int32
+	ctx := newHostsContext(map[string]interface{}{"parallel": true, "max-parallel": 2})
+	res := runOnHosts(ctx, newHostTargets("a", "b", "c", "d"), func(ctx context.Context, target hostTarget, stdout io.Writer, stderr io.Writer) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return 0, nil
+	})
+
+	assert.Equal(t, statuses.Ok, res.Status)
+	assert.Equal(t, int32(2), peak)
+}
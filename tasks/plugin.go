@@ -0,0 +1,202 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// PluginProtocolVersion is the version of the JSON protocol used to
+// communicate with xtask-<name> plugins.
+const PluginProtocolVersion = 1
+
+// PluginRequest is written as JSON to the stdin of a plugin.
+type PluginRequest struct {
+	Version int                    `json:"version"`
+	Id      string                 `json:"id"`
+	Uses    string                 `json:"uses"`
+	Run     string                 `json:"run"`
+	With    map[string]interface{} `json:"with"`
+	Env     map[string]string      `json:"env"`
+	Cwd     string                 `json:"cwd"`
+	Hosts   map[string]PluginHost  `json:"hosts"`
+	Args    []string               `json:"args"`
+	Timeout string                 `json:"timeout,omitempty"`
+	Context string                 `json:"context"`
+}
+
+// PluginHost is a host of the task as sent to a plugin.
+type PluginHost struct {
+	Host     string                 `json:"host"`
+	Port     *int                   `json:"port,omitempty"`
+	User     *string                `json:"user,omitempty"`
+	Identity *string                `json:"identity,omitempty"`
+	Groups   []string               `json:"groups,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+}
+
+// PluginResponse is read as JSON from the stdout of a plugin. The status
+// is one of ok, error, skipped or cancelled.
+type PluginResponse struct {
+	Status  string                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// pluginName is the form of the names of plugins, which are used in the
+// name of the executable and of its env var.
+var pluginName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// FindPlugin returns the path of the xtask-<name> executable for uses on
+// the PATH of e. The path may be overridden with the
+// XTASK_PLUGIN_<NAME>_EXE variable. Names other than lowercase letters,
+// digits and dashes are not plugins.
+func FindPlugin(uses string, e *types.Env) (string, bool) {
+	name := runnerScheme(uses)
+	if !pluginName.MatchString(name) {
+		return "", false
+	}
+
+	exe := "xtask-" + name
+	path := ""
+	withTaskEnv(e, func() {
+		// the exec registry is not safe for concurrent use, so the
+		// plugin is registered while holding the env lock.
+		if !exec.Registry.Has(exe) {
+			variable := "XTASK_PLUGIN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_EXE"
+			exec.Register(exe, &exec.Executable{
+				Name:     exe,
+				Variable: variable,
+				Linux:    []string{exe},
+				Windows:  []string{exe},
+			})
+		}
+
+		p, err := exec.Find(exe, nil)
+		if err == nil {
+			path = p
+		}
+	})
+
+	return path, path != ""
+}
+
+func runPlugin(ctx TaskContext, path string) *TaskResult {
+	res := NewTaskResult()
+
+	hosts := map[string]PluginHost{}
+	for name, h := range ctx.Data.Hosts {
+		hosts[name] = PluginHost{
+			Host:     h.Host,
+			Port:     h.Port,
+			User:     h.User,
+			Identity: h.Identity,
+			Groups:   h.Groups,
+			Meta:     h.Meta,
+		}
+	}
+
+	req := PluginRequest{
+		Version: PluginProtocolVersion,
+		Id:      ctx.Data.Id,
+		Uses:    ctx.Data.Uses,
+		Run:     ctx.Data.Run,
+		With:    ctx.Data.With,
+		Env:     ctx.Data.Env.ToMap(),
+		Cwd:     ctx.Data.Cwd,
+		Hosts:   hosts,
+		Args:    ctx.Args,
+		Context: ctx.ContextName,
+	}
+
+	if ctx.Data.Timeout > 0 {
+		req.Timeout = ctx.Data.Timeout.String()
+	}
+
+	if req.With == nil {
+		req.With = map[string]interface{}{}
+	}
+
+	if req.Args == nil {
+		req.Args = []string{}
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return res.Fail(errors.New("Failed to encode plugin request: " + err.Error()))
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.NewContext(ctx.Context, path)
+	cmd.Dir = ctx.Data.Cwd
+	cmd.WithEnvMap(ctx.Data.Env.ToMap())
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	waited := setProcessGroup(cmd, killGracePeriod)
+
+	res.Start()
+	err = cmd.Start()
+	if err == nil {
+		err = cmd.Wait()
+	}
+	waited()
+
+	if ctxErr := ctx.Context.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return res.Cancel("Task " + ctx.Task.Id + " timed out after " + ctx.Data.Timeout.String())
+		}
+
+		return res.Cancel("Task " + ctx.Task.Id + " cancelled")
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		if err != nil {
+			return res.Fail(errors.New("Plugin " + path + " failed: " + err.Error()))
+		}
+
+		return res.Ok()
+	}
+
+	var resp PluginResponse
+	if jsonErr := json.Unmarshal(out, &resp); jsonErr != nil {
+		if err != nil {
+			return res.Fail(errors.New("Plugin " + path + " failed: " + err.Error()))
+		}
+
+		return res.Fail(errors.New("Invalid response from plugin " + path + ": " + jsonErr.Error()))
+	}
+
+	for k, v := range resp.Outputs {
+		res.Output[k] = v
+	}
+
+	switch strings.ToLower(resp.Status) {
+	case "ok", "":
+		if err != nil {
+			return res.Fail(errors.New("Plugin " + path + " failed: " + err.Error()))
+		}
+		res.Message = resp.Message
+		return res.Ok()
+	case "skipped":
+		return res.Skip(resp.Message)
+	case "cancelled":
+		return res.Cancel(resp.Message)
+	case "error":
+		msg := resp.Message
+		if msg == "" {
+			msg = "Plugin " + path + " reported an error"
+		}
+		return res.Fail(errors.New(msg))
+	default:
+		return res.Fail(errors.New("Invalid status from plugin " + path + ": " + resp.Status))
+	}
+}
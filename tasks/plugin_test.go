@@ -0,0 +1,98 @@
+package tasks_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeStubPlugin(t *testing.T, name string, script string) string {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "xtask-"+name)
+	assert.NoError(t, os.WriteFile(file, []byte("#!/bin/sh\n"+script), 0755))
+	return dir
+}
+
+func TestRunPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub plugin is a shell script")
+	}
+
+	dir := writeStubPlugin(t, "stub", `cat > "$STUB_REQUEST"
+echo '{"status":"ok","message":"done","outputs":{"version":"1.0.0"}}'
+`)
+	request := filepath.Join(t.TempDir(), "request.json")
+
+	e := types.NewEnv()
+	e.Set("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	e.Set("STUB_REQUEST", request)
+
+	ctx := tasks.TaskContext{Context: context.Background(), Args: []string{"--fast"}}
+	ctx.Data = tasks.TaskData{
+		Id:   "deploy",
+		Uses: "stub://cluster",
+		Run:  "apply",
+		Env:  *e,
+		Cwd:  t.TempDir(),
+		With: map[string]interface{}{"replicas": 2},
+	}
+
+	path, ok := tasks.FindPlugin("stub://cluster", e)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "xtask-stub"), path)
+
+	// names that are not plugin names are rejected even when an env var
+	// would map them to an executable.
+	e.Set("XTASK_PLUGIN_STUB_X_EXE", path)
+	for _, uses := range []string{"stub_x://cluster", "../stub://cluster", "Stub://cluster", "-stub", "stub/x"} {
+		_, ok = tasks.FindPlugin(uses, e)
+		assert.False(t, ok, uses)
+	}
+
+	res := tasks.Run(ctx)
+	assert.NoError(t, res.Err)
+	assert.Equal(t, statuses.Ok, res.Status)
+	assert.Equal(t, "done", res.Message)
+	assert.Equal(t, "1.0.0", res.Output["version"])
+
+	bytes, err := os.ReadFile(request)
+	assert.NoError(t, err)
+
+	var req tasks.PluginRequest
+	assert.NoError(t, json.Unmarshal(bytes, &req))
+	assert.Equal(t, tasks.PluginProtocolVersion, req.Version)
+	assert.Equal(t, "deploy", req.Id)
+	assert.Equal(t, "stub://cluster", req.Uses)
+	assert.Equal(t, "apply", req.Run)
+	assert.Equal(t, []string{"--fast"}, req.Args)
+	assert.Equal(t, float64(2), req.With["replicas"])
+	assert.Equal(t, request, req.Env["STUB_REQUEST"])
+}
+
+func TestRunPluginError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub plugin is a shell script")
+	}
+
+	dir := writeStubPlugin(t, "broken", `echo '{"status":"error","message":"cluster unreachable"}'
+exit 1
+`)
+
+	e := types.NewEnv()
+	e.Set("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	ctx := tasks.TaskContext{Context: context.Background()}
+	ctx.Data = tasks.TaskData{Id: "deploy", Uses: "broken", Env: *e}
+
+	res := tasks.Run(ctx)
+	assert.Equal(t, statuses.Error, res.Status)
+	assert.ErrorContains(t, res.Err, "cluster unreachable")
+}
@@ -0,0 +1,64 @@
+package tasks
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+var prefixColors = []string{"\x1b[36m", "\x1b[35m", "\x1b[33m", "\x1b[32m", "\x1b[34m", "\x1b[31m"}
+
+// prefixWriter writes each line with a prefix. Lines are buffered until
+// they are complete so that the output of several hosts does not mix
+// within a line. Writers that share mu write whole lines one at a time.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(mu *sync.Mutex, out io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{mu: mu, out: out, prefix: []byte(prefix)}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the remaining output that did not end with a new line.
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.out.Write(w.prefix); err != nil {
+		return err
+	}
+
+	_, err := w.out.Write(line)
+	return err
+}
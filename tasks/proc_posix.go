@@ -0,0 +1,53 @@
+//go:build !windows
+
+package tasks
+
+import (
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+)
+
+// setProcessGroup starts cmd in a new process group. When the context of
+// cmd is done, SIGTERM is sent to the whole group so that child processes
+// started by the script are stopped as well. Any process still running
+// after grace is sent SIGKILL. The returned func must be called once cmd
+// was waited for, so that the group id is not killed after it could be
+// reused by another process group.
+func setProcessGroup(cmd *exec.Cmd, grace time.Duration) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+	waited := false
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pid := cmd.Process.Pid
+		err := syscall.Kill(-pid, syscall.SIGTERM)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !waited {
+			timer = time.AfterFunc(grace, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if !waited {
+					syscall.Kill(-pid, syscall.SIGKILL)
+				}
+			})
+		}
+
+		return err
+	}
+	cmd.WaitDelay = grace + time.Second
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		waited = true
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
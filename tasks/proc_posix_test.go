@@ -0,0 +1,28 @@
+//go:build !windows
+
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetProcessGroupKillsAfterGrace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the group ignores SIGTERM, so only the SIGKILL after grace stops it.
+	cmd := exec.NewContext(ctx, "sh", "-c", "trap '' TERM; sleep 10")
+	waited := setProcessGroup(cmd, 200*time.Millisecond)
+
+	start := time.Now()
+	_, err := cmd.Run()
+	waited()
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
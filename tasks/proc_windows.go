@@ -0,0 +1,26 @@
+//go:build windows
+
+package tasks
+
+import (
+	osexec "os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+)
+
+// setProcessGroup starts cmd in a new process group. When the context of
+// cmd is done, the process tree is terminated with taskkill as windows
+// has no equivalent of sending SIGTERM to a process group. The returned
+// func does nothing as the tree is killed right away.
+func setProcessGroup(cmd *exec.Cmd, grace time.Duration) func() {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		pid := strconv.Itoa(cmd.Process.Pid)
+		return osexec.Command("taskkill", "/T", "/F", "/PID", pid).Run()
+	}
+	cmd.WaitDelay = grace + time.Second
+	return func() {}
+}
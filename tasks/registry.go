@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Runner runs tasks for a `uses` type.
+type Runner interface {
+	Run(ctx TaskContext) *TaskResult
+}
+
+// RunnerFunc adapts a function to a Runner.
+type RunnerFunc func(ctx TaskContext) *TaskResult
+
+func (f RunnerFunc) Run(ctx TaskContext) *TaskResult {
+	return f(ctx)
+}
+
+// OptionsRunner is a Runner that declares the keys of with it reads, so
+// that shared tasks using it can reject the keys that are not inputs.
+type OptionsRunner interface {
+	Runner
+	Options() []string
+}
+
+type optionsRunner struct {
+	Runner
+	options []string
+}
+
+func (r optionsRunner) Options() []string {
+	return r.options
+}
+
+// WithOptions returns runner as an OptionsRunner that reads the options.
+func WithOptions(runner Runner, options ...string) OptionsRunner {
+	return optionsRunner{Runner: runner, options: options}
+}
+
+var registry = struct {
+	sync.RWMutex
+	runners map[string]Runner
+}{
+	runners: map[string]Runner{},
+}
+
+// Register adds the runner for tasks whose `uses` is name or a URI with
+// name as the scheme, e.g. `k8s` for `uses: k8s://cluster/namespace`. A
+// runner registered with the same name is replaced, which allows the
+// built-in runners to be overridden.
+func Register(name string, runner Runner) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if runner == nil {
+		delete(registry.runners, name)
+		return
+	}
+
+	registry.runners[name] = runner
+}
+
+// Lookup returns the runner for uses, which may be a name or a URI.
+func Lookup(uses string) (Runner, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	runner, ok := registry.runners[runnerScheme(uses)]
+	return runner, ok
+}
+
+// RunnerOptions returns the keys of with read by the runner for uses. The
+// options are nil when the runner is unknown or does not declare them.
+func RunnerOptions(uses string) []string {
+	runner, ok := Lookup(uses)
+	if !ok {
+		return nil
+	}
+
+	if r, ok := runner.(OptionsRunner); ok {
+		options := r.Options()
+		if options == nil {
+			options = []string{}
+		}
+		return options
+	}
+
+	return nil
+}
+
+// Runners returns the sorted names of the registered runners.
+func Runners() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.runners))
+	for name := range registry.runners {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// runnerScheme returns the scheme of a `uses` URI or uses itself. The
+// scheme is split off instead of using url.Parse as the rest of the URI
+// is not always a valid URL, e.g. `docker://node:lts`.
+func runnerScheme(uses string) string {
+	if i := strings.Index(uses, "://"); i > -1 {
+		return uses[:i]
+	}
+
+	return uses
+}
+
+func init() {
+	hostOptions := []string{"parallel", "max-parallel", "fail-fast"}
+	Register("tmpl", WithOptions(RunnerFunc(runTpl), "files", "values", "disable-env", "disable-gotmpl"))
+	Register("scp", WithOptions(RunnerFunc(runSCP), append([]string{"files", "checksum", "delete", "preserve-mode"}, hostOptions...)...))
+	Register("ssh", WithOptions(RunnerFunc(runSSH), append([]string{"shell", "temp-dir"}, hostOptions...)...))
+	Register("ssh-tunnel", WithOptions(RunnerFunc(runSSHTunnel), "forward", "remote-forward"))
+	Register("docker", WithOptions(RunnerFunc(runDocker), "image", "entrypoint", "network", "ports", "pull", "shell", "user", "volumes", "workdir"))
+	for _, shell := range []string{"bash", "sh", "zsh", "powershell", "pwsh", "cmd", "python", "ruby", "deno", "node", "bun"} {
+		Register(shell, WithOptions(RunnerFunc(runShell)))
+	}
+}
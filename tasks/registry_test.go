@@ -0,0 +1,41 @@
+package tasks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterRunner(t *testing.T) {
+	var got string
+	tasks.Register("k8s", tasks.RunnerFunc(func(ctx tasks.TaskContext) *tasks.TaskResult {
+		got = ctx.Data.Uses
+		return tasks.NewTaskResult().Ok()
+	}))
+	defer tasks.Register("k8s", nil)
+
+	assert.Contains(t, tasks.Runners(), "k8s")
+	assert.Equal(t, "k8s", tasks.RunnerName("k8s://cluster/default"))
+
+	ctx := tasks.TaskContext{Context: context.Background()}
+	ctx.Data.Uses = "k8s://cluster/default"
+	res := tasks.Run(ctx)
+	assert.Equal(t, statuses.Ok, res.Status)
+	assert.Equal(t, "k8s://cluster/default", got)
+}
+
+func TestRunUnsupportedRunner(t *testing.T) {
+	ctx := tasks.TaskContext{Context: context.Background()}
+	ctx.Data.Uses = "unknown://x"
+	res := tasks.Run(ctx)
+	assert.Equal(t, statuses.Error, res.Status)
+	assert.Equal(t, "", tasks.RunnerName("unknown://x"))
+
+	for _, name := range []string{"bash", "ssh", "scp", "docker", "tmpl"} {
+		_, ok := tasks.Lookup(name)
+		assert.True(t, ok, name)
+	}
+}
@@ -1,8 +1,8 @@
 package tasks
 
 import (
-	"net/url"
-	"strings"
+	"path/filepath"
+	"sync"
 
 	"github.com/hyprxlabs/go/env"
 	"github.com/hyprxlabs/go/exec"
@@ -52,41 +52,57 @@ func (t *taskEnvLike) SplitPath() []string {
 	return t.Env.SplitPath()
 }
 
-func Run(ctx TaskContext) *TaskResult {
+var envLikeMu sync.Mutex
+
+// withTaskEnv sets the env used by the exec package for finding executables
+// to the task env while fn runs. The exec package only holds a single
+// env, so the swap is serialized as tasks may run concurrently. Commands
+// created in fn should be resolved to an absolute path with resolveCmd
+// so that starting them later does not search the PATH again.
+func withTaskEnv(e *types.Env, fn func()) {
+	envLikeMu.Lock()
+	defer envLikeMu.Unlock()
 
-	// Set custom env like for exec package
-	// so that the task env is used for finding executables
 	oldEnv := exec.GetEnvLike()
 	defer exec.SetEnvLike(oldEnv)
-	envLike := &taskEnvLike{Env: &ctx.Data.Env}
-	exec.SetEnvLike(envLike)
+	exec.SetEnvLike(&taskEnvLike{Env: e})
 
-	uses := ctx.Data.Uses
-	if strings.Contains(uses, "://") {
-		uri, err := url.Parse(uses)
+	fn()
+}
 
-		if err != nil {
-			res := NewTaskResult()
-			return res.Fail(errors.New("Invalid template URI: " + err.Error()))
-		}
+// resolveCmd resolves the path of cmd using the current exec env. It must
+// be called from within withTaskEnv.
+func resolveCmd(cmd *exec.Cmd) {
+	if cmd == nil || cmd.Path == "" || filepath.IsAbs(cmd.Path) {
+		return
+	}
+
+	if p, err := exec.Find(cmd.Path, nil); err == nil && p != "" {
+		cmd.Path = p
+	}
+}
 
-		uses = uri.Scheme
+// RunnerName returns the name of the registered runner used for a task
+// with the given uses, e.g. "ssh" for "ssh://host". An empty string is
+// returned when no runner supports uses.
+func RunnerName(uses string) string {
+	if _, ok := Lookup(uses); !ok {
+		return ""
 	}
 
-	switch uses {
-	case "tmpl":
-		return runTpl(ctx)
-	case "scp":
-		return runSCP(ctx)
-	case "ssh":
-		return runSSH(ctx)
-	case "docker":
-		return runDocker(ctx)
-	case "bash", "sh", "zsh", "powershell", "pwsh", "cmd", "python", "ruby", "deno", "node", "bun":
-		return runShell(ctx)
-	default:
-		// Unsupported task type
+	return runnerScheme(uses)
+}
+
+func Run(ctx TaskContext) *TaskResult {
+	runner, ok := Lookup(ctx.Data.Uses)
+	if !ok {
+		if path, ok := FindPlugin(ctx.Data.Uses, &ctx.Data.Env); ok {
+			return runPlugin(ctx, path)
+		}
+
 		res := NewTaskResult()
-		return res.Fail(errors.NewDetails("Unsupported task type: "+uses, "unsupported_task_type", "The task type is not supported"))
+		return res.Fail(errors.NewDetails("Unsupported task type: "+runnerScheme(ctx.Data.Uses), "unsupported_task_type", "The task type is not supported"))
 	}
+
+	return runner.Run(ctx)
 }
@@ -3,16 +3,12 @@ package tasks
 import (
 	"context"
 	"io"
-	"net"
 	"net/url"
 	"os"
-	"strconv"
-	"strings"
 
 	"github.com/hyprxlabs/xtask/errors"
 	"github.com/hyprxlabs/xtask/types"
 	goph "github.com/melbahja/goph"
-	"golang.org/x/crypto/ssh"
 )
 
 func runSCP(ctx TaskContext) *TaskResult {
@@ -25,22 +21,16 @@ func runSCP(ctx TaskContext) *TaskResult {
 		uses = "scp://"
 	}
 
-	files := []string{}
-	if v, ok := ctx.Data.With["files"]; ok {
-		if arr, ok := v.([]interface{}); ok {
-			for _, item := range arr {
-				if str, ok := item.(string); ok {
-					files = append(files, str)
-				}
-			}
-		}
+	files, err := parseFileSpecs(ctx.Data.With)
+	if err != nil {
+		return res.Fail(err)
 	}
 
 	if len(files) == 0 {
 		return res.Fail(errors.New("No files specified for SCP task"))
 	}
 
-	uri, err := url.Parse(ctx.Data.Uses)
+	uri, err := url.Parse(uses)
 	if err != nil {
 		return res.Fail(errors.New("Invalid SSH URI: " + err.Error()))
 	}
@@ -63,143 +53,68 @@ func runSCP(ctx TaskContext) *TaskResult {
 		}
 	}
 
-	targets := []types.Host{}
+	targets := []hostTarget{}
 	if uri.Host != "" {
-		user := ""
-		if uri.User != nil {
-			user = uri.User.Username()
-		}
-
-		port := 22
-		if uri.Port() != "" {
-			port, err = strconv.Atoi(uri.Port())
-			if err != nil {
-				return res.Fail(errors.New("Invalid port in SSH URI: " + err.Error()))
-			}
-		}
-
-		password, ok := uri.User.Password()
-		if ok && password != "" {
-			p, ok := ctx.Data.Env.Get(password)
-			if ok {
-				password = p
-			}
+		target, err := uriHostTarget(uri, ctx.Inventory)
+		if err != nil {
+			return res.Fail(err)
 		}
 
-		identity := uri.Query().Get("identity")
-
-		targets = append(targets, types.Host{
-			Host:     uri.Host,
-			User:     &user,
-			Port:     &port,
-			Identity: &identity,
-			Password: &password,
-		})
+		targets = append(targets, target)
 	} else if len(ctx.Data.Hosts) > 0 {
-		for _, h := range ctx.Data.Hosts {
-			targets = append(targets, h)
-		}
+		targets = hostTargets(ctx.Data.Hosts)
 	}
 
 	if len(targets) == 0 {
 		return res.Fail(errors.New("No targets found for SSH task"))
 	}
 
-	for _, target := range targets {
-		if err := runScpTarget(ctx.Context, direction, ctx, target, files); err != nil {
-			return res.Fail(err)
-		}
-	}
-
-	res.End()
-
-	// Placeholder for running an SSH command
-	// This would typically involve executing the command over SSH
-	return res.Ok()
+	return runOnHosts(ctx, targets, func(c context.Context, target hostTarget, stdout io.Writer, stderr io.Writer) (int, error) {
+		return -1, runScpTarget(c, direction, ctx, target.alias, target.host, files, stdout)
+	})
 }
 
-func runScpTarget(ctx context.Context, direction string, taskContext TaskContext, target types.Host, files []string) error {
-	var auth goph.Auth
-	var err error
-	identity := ""
-	password := ""
-	if target.Identity != nil {
-		identity = *target.Identity
-	}
-	if target.Password != nil {
-		password = *target.Password
-		if password != "" {
-			p, ok := taskContext.Data.Env.Get(password)
-			if ok {
-				password = p
-			}
-		}
+func runScpTarget(ctx context.Context, direction string, taskContext TaskContext, alias string, target types.Host, files []fileSpec, stdout io.Writer) error {
+	client, release, err := connectSSH(taskContext, alias, target)
+	if err != nil {
+		return err
 	}
 
-	if identity == "" && password != "" {
-		auth = goph.Password(password)
-	} else if goph.HasAgent() {
-		auth, err = goph.UseAgent()
-	} else if identity != "" {
-		auth, err = goph.Key(identity, password)
-	} else {
-		return errors.New("No authentication method provided for SSH task")
-	}
+	defer release()
 
+	ftp, err := client.NewSftp()
 	if err != nil {
-		return errors.New("Failed to create SSH authentication: " + err.Error())
+		return errors.New("Failed to start sftp: " + err.Error())
 	}
+	defer ftp.Close()
 
-	port := 22
-	if target.Port != nil && *target.Port > 0 {
-		port = int(*target.Port)
-	}
-	user := ""
-	if target.User != nil && *target.User != "" {
-		user = *target.User
-	}
-
-	client, err := goph.NewConn(&goph.Config{
-		User: user,
-		Addr: target.Host,
-		Port: uint(port),
-		Auth: auth,
-		Callback: func(host string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		},
-	})
-
-	if err != nil {
-		err2 := errors.New("Failed to connect to SSH target " + target.Host + ": " + err.Error())
-		err2 = errors.WithCause(err2, err)
-		return err2
+	opts := syncOptions{preserveMode: true}
+	opts.checksum, _ = withBool(taskContext.Data.With, "checksum")
+	opts.delete, _ = withBool(taskContext.Data.With, "delete")
+	if preserve, ok := withBool(taskContext.Data.With, "preserve-mode"); ok {
+		opts.preserveMode = preserve
 	}
 
-	defer client.Close()
+	local := localFS{dir: taskContext.Data.Cwd, env: &taskContext.Data.Env}
+	remote := remoteFS{client: ftp}
 
 	for _, file := range files {
-		parts := strings.Split(file, ":")
-		if len(parts) != 2 {
-			err2 := errors.New("Invalid SCP file format, expected 'source:destination'")
-			err2 = errors.WithCause(err2, err)
-			return err2
-		}
-		source := parts[0]
-		destination := parts[1]
-
+		var src, dst syncFS = local, remote
 		if direction != "download" {
-			os.Stdout.WriteString("Uploading " + source + " to " + destination + " on " + target.Host + "\n")
-			err = Upload(ctx, client, source, destination)
+			io.WriteString(stdout, "Uploading "+file.source+" to "+file.destination+" on "+target.Host+"\n")
 		} else {
-			os.Stdout.WriteString("Downloading " + source + " to " + destination + " from " + target.Host + "\n")
-			err = Download(ctx, client, destination, source)
+			src, dst = remote, local
+			io.WriteString(stdout, "Downloading "+file.source+" to "+file.destination+" from "+target.Host+"\n")
 		}
 
+		stats, err := syncFiles(ctx, src, dst, file, opts, stdout)
 		if err != nil {
-			err2 := errors.New("Failed to transfer file " + source + " to " + destination + ": " + err.Error())
+			err2 := errors.New("Failed to transfer " + file.source + " to " + file.destination + ": " + err.Error())
 			err2 = errors.WithCause(err2, err)
 			return err2
 		}
+
+		io.WriteString(stdout, "  "+stats.String()+"\n")
 	}
 
 	return nil
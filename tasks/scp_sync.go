@@ -0,0 +1,472 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/paths"
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/pkg/sftp"
+)
+
+// progressSize is the size from which the progress of a file is reported
+// while it is copied.
+const progressSize = 8 << 20
+
+// fileSpec is a source and destination of the files of an scp task.
+type fileSpec struct {
+	source      string
+	destination string
+}
+
+// syncOptions are the with options of an scp task.
+type syncOptions struct {
+	checksum     bool
+	delete       bool
+	preserveMode bool
+}
+
+type syncStats struct {
+	copied    int
+	unchanged int
+	deleted   int
+	bytes     int64
+}
+
+func (s syncStats) String() string {
+	return strconv.Itoa(s.copied) + " copied, " + strconv.Itoa(s.unchanged) + " unchanged, " +
+		strconv.Itoa(s.deleted) + " deleted, " + formatSize(s.bytes)
+}
+
+// syncFS is the local or remote side of a copy. Names use the separator of
+// the side, the relative paths of files use forward slashes.
+type syncFS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error)
+	MkdirAll(dir string) error
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, mtime time.Time) error
+	Remove(name string) error
+	// Files returns the regular files under dir, relative to dir.
+	Files(dir string) ([]string, error)
+	Join(elem ...string) string
+	Dir(name string) string
+}
+
+// localFS resolves relative names from dir, the cwd of the task.
+type localFS struct {
+	dir string
+	env *types.Env
+}
+
+func (l localFS) abs(name string) string {
+	name = expandHome(name, l.env)
+	if l.dir != "" && !filepath.IsAbs(name) {
+		return filepath.Join(l.dir, name)
+	}
+	return name
+}
+
+func (l localFS) Stat(name string) (fs.FileInfo, error)   { return os.Stat(l.abs(name)) }
+func (l localFS) Open(name string) (io.ReadCloser, error) { return os.Open(l.abs(name)) }
+func (l localFS) Create(name string) (io.WriteCloser, error) {
+	return os.OpenFile(l.abs(name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+func (l localFS) MkdirAll(dir string) error { return os.MkdirAll(l.abs(dir), 0755) }
+func (l localFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(l.abs(name), mode)
+}
+func (l localFS) Chtimes(name string, mtime time.Time) error {
+	return os.Chtimes(l.abs(name), mtime, mtime)
+}
+func (l localFS) Remove(name string) error           { return os.Remove(l.abs(name)) }
+func (l localFS) Files(dir string) ([]string, error) { return paths.Glob(l.abs(dir), []string{"**"}) }
+func (l localFS) Join(elem ...string) string {
+	for i := range elem {
+		elem[i] = filepath.FromSlash(elem[i])
+	}
+	return filepath.Join(elem...)
+}
+func (l localFS) Dir(name string) string { return filepath.Dir(name) }
+
+type remoteFS struct {
+	client *sftp.Client
+}
+
+func (r remoteFS) Stat(name string) (fs.FileInfo, error)   { return r.client.Stat(name) }
+func (r remoteFS) Open(name string) (io.ReadCloser, error) { return r.client.Open(name) }
+func (r remoteFS) Create(name string) (io.WriteCloser, error) {
+	return r.client.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+}
+func (r remoteFS) MkdirAll(dir string) error { return r.client.MkdirAll(dir) }
+func (r remoteFS) Chmod(name string, mode fs.FileMode) error {
+	return r.client.Chmod(name, mode)
+}
+func (r remoteFS) Chtimes(name string, mtime time.Time) error {
+	return r.client.Chtimes(name, mtime, mtime)
+}
+func (r remoteFS) Remove(name string) error   { return r.client.Remove(name) }
+func (r remoteFS) Join(elem ...string) string { return path.Join(elem...) }
+func (r remoteFS) Dir(name string) string     { return path.Dir(name) }
+
+func (r remoteFS) Files(dir string) ([]string, error) {
+	files := []string{}
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	walker := r.client.Walk(dir)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+
+		if walker.Stat().Mode().IsRegular() {
+			files = append(files, strings.TrimPrefix(walker.Path(), prefix))
+		}
+	}
+
+	return files, nil
+}
+
+// parseFileSpecs reads with.files, a list of source:destination strings or
+// of objects with source and destination fields.
+func parseFileSpecs(with map[string]interface{}) ([]fileSpec, error) {
+	specs := []fileSpec{}
+	items, _ := with["files"].([]interface{})
+	for _, item := range items {
+		switch value := item.(type) {
+		case string:
+			source, destination, ok := splitFileSpec(value)
+			if !ok {
+				return nil, errors.New("Invalid SCP file format, expected 'source:destination': " + value)
+			}
+			specs = append(specs, fileSpec{source: source, destination: destination})
+		case map[string]interface{}:
+			spec := fileSpec{
+				source:      withString(value, "source"),
+				destination: withString(value, "destination"),
+			}
+			if spec.source == "" {
+				spec.source = withString(value, "src")
+			}
+			if spec.destination == "" {
+				spec.destination = withString(value, "dest")
+			}
+
+			if spec.source == "" || spec.destination == "" {
+				return nil, errors.New("Invalid SCP file entry, expected source and destination")
+			}
+			specs = append(specs, spec)
+		}
+	}
+
+	return specs, nil
+}
+
+// splitFileSpec splits source:destination at the first colon that is not
+// the colon of a Windows drive letter such as C:\ or C:/.
+func splitFileSpec(spec string) (string, string, bool) {
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != ':' || isDriveColon(spec, i) {
+			continue
+		}
+
+		if i == 0 || i == len(spec)-1 {
+			return "", "", false
+		}
+
+		return spec[:i], spec[i+1:], true
+	}
+
+	return "", "", false
+}
+
+// isDriveColon reports whether the colon at i follows a drive letter. As
+// a:/x may also be the file a copied to /x, a drive letter at the start of
+// the spec is only taken as one on Windows or when a later colon separates
+// the destination.
+func isDriveColon(s string, i int) bool {
+	if i < 1 || i+1 >= len(s) || (i >= 2 && s[i-2] != ':') {
+		return false
+	}
+
+	c := s[i-1]
+	isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	if !isLetter || (s[i+1] != '\\' && s[i+1] != '/') {
+		return false
+	}
+
+	return i > 1 || runtime.GOOS == "windows" || strings.Contains(s[i+1:], ":")
+}
+
+// splitGlob returns the directory before the first segment with a glob
+// character and the slash separated pattern after it.
+func splitGlob(name string) (string, string, bool) {
+	segments := strings.Split(filepath.ToSlash(name), "/")
+	for i, segment := range segments {
+		if !strings.ContainsAny(segment, "*?[") {
+			continue
+		}
+
+		base := strings.Join(segments[:i], "/")
+		if base == "" && i > 0 {
+			base = "/"
+		} else if base == "" {
+			base = "."
+		}
+
+		return base, strings.Join(segments[i:], "/"), true
+	}
+
+	return name, "", false
+}
+
+type syncItem struct {
+	from string
+	to   string
+	rel  string
+}
+
+// planSync lists the files to copy for spec. A directory source copies the
+// files in the directory into the destination directory and a glob source
+// copies the matches, keeping their path relative to the directory before
+// the glob. root and pattern select the destination files that delete
+// may remove; root is empty for a single file.
+func planSync(src syncFS, dst syncFS, spec fileSpec) ([]syncItem, string, string, error) {
+	items := []syncItem{}
+	if base, pattern, ok := splitGlob(spec.source); ok {
+		files, err := src.Files(base)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		for _, rel := range files {
+			if paths.Match(pattern, rel) {
+				items = append(items, syncItem{from: src.Join(base, rel), to: dst.Join(spec.destination, rel), rel: rel})
+			}
+		}
+
+		if len(items) == 0 {
+			return nil, "", "", errors.New("No files match " + spec.source)
+		}
+
+		return items, spec.destination, pattern, nil
+	}
+
+	info, err := src.Stat(spec.source)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if info.IsDir() {
+		files, err := src.Files(spec.source)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		for _, rel := range files {
+			items = append(items, syncItem{from: src.Join(spec.source, rel), to: dst.Join(spec.destination, rel), rel: rel})
+		}
+
+		return items, spec.destination, "**", nil
+	}
+
+	name := path.Base(filepath.ToSlash(spec.source))
+	to := spec.destination
+	if strings.HasSuffix(to, "/") || strings.HasSuffix(to, "\\") {
+		to = dst.Join(to, name)
+	} else if info, err := dst.Stat(to); err == nil && info.IsDir() {
+		to = dst.Join(to, name)
+	}
+
+	return []syncItem{{from: spec.source, to: to, rel: name}}, "", "", nil
+}
+
+// syncFiles copies the files of spec from src to dst. Files with the same
+// size and modification time are skipped, or with the same content when
+// checksum is set. Copied files get the modification time of the source.
+func syncFiles(ctx context.Context, src syncFS, dst syncFS, spec fileSpec, opts syncOptions, out io.Writer) (syncStats, error) {
+	stats := syncStats{}
+	items, root, pattern, err := planSync(src, dst, spec)
+	if err != nil {
+		return stats, err
+	}
+
+	keep := map[string]bool{}
+	for _, item := range items {
+		keep[item.rel] = true
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		info, err := src.Stat(item.from)
+		if err != nil {
+			return stats, err
+		}
+
+		same, err := unchanged(src, dst, item, info, opts.checksum)
+		if err != nil {
+			return stats, err
+		}
+
+		if same {
+			stats.unchanged++
+			continue
+		}
+
+		if err := dst.MkdirAll(dst.Dir(item.to)); err != nil {
+			return stats, errors.New("Failed to create directory " + dst.Dir(item.to) + ": " + err.Error())
+		}
+
+		if err := copyFile(ctx, src, dst, item, info.Size(), out); err != nil {
+			return stats, err
+		}
+
+		if opts.preserveMode {
+			if err := dst.Chmod(item.to, info.Mode().Perm()); err != nil {
+				return stats, errors.New("Failed to set the mode of " + item.to + ": " + err.Error())
+			}
+		}
+
+		if err := dst.Chtimes(item.to, info.ModTime()); err != nil {
+			return stats, errors.New("Failed to set the modification time of " + item.to + ": " + err.Error())
+		}
+
+		stats.copied++
+		stats.bytes += info.Size()
+		io.WriteString(out, "  "+item.rel+" ("+formatSize(info.Size())+")\n")
+	}
+
+	if !opts.delete || root == "" {
+		return stats, nil
+	}
+
+	existing, err := dst.Files(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stats, nil
+	}
+
+	if err != nil {
+		return stats, errors.New("Failed to list the files to delete in " + root + ": " + err.Error())
+	}
+
+	for _, rel := range existing {
+		if keep[rel] || !paths.Match(pattern, rel) {
+			continue
+		}
+
+		if err := dst.Remove(dst.Join(root, rel)); err != nil {
+			return stats, errors.New("Failed to delete " + rel + ": " + err.Error())
+		}
+
+		stats.deleted++
+		io.WriteString(out, "  deleted "+rel+"\n")
+	}
+
+	return stats, nil
+}
+
+func unchanged(src syncFS, dst syncFS, item syncItem, info fs.FileInfo, checksum bool) (bool, error) {
+	existing, err := dst.Stat(item.to)
+	if err != nil || !existing.Mode().IsRegular() || existing.Size() != info.Size() {
+		return false, nil
+	}
+
+	if !checksum {
+		// sftp only keeps whole seconds.
+		return existing.ModTime().Unix() == info.ModTime().Unix(), nil
+	}
+
+	a, err := hashSyncFile(src, item.from)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := hashSyncFile(dst, item.to)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(a, b), nil
+}
+
+func hashSyncFile(f syncFS, name string) ([]byte, error) {
+	r, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+func copyFile(ctx context.Context, src syncFS, dst syncFS, item syncItem, size int64, out io.Writer) error {
+	r, err := src.Open(item.from)
+	if err != nil {
+		return errors.New("Failed to open " + item.from + ": " + err.Error())
+	}
+	defer r.Close()
+
+	w, err := dst.Create(item.to)
+	if err != nil {
+		return errors.New("Failed to create " + item.to + ": " + err.Error())
+	}
+
+	var copied int64
+	next := int64(25)
+	_, err = io.Copy(w, readerFunc(func(p []byte) (int, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		n, err := r.Read(p)
+		copied += int64(n)
+		if size >= progressSize && next < 100 && copied*100/size >= next {
+			io.WriteString(out, "  "+item.rel+" "+strconv.FormatInt(next, 10)+"%\n")
+			next += 25
+		}
+
+		return n, err
+	}))
+
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errors.New("Failed to copy " + item.from + " to " + item.to + ": " + err.Error())
+	}
+
+	return nil
+}
+
+func formatSize(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + units[i]
+}
@@ -0,0 +1,131 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitFileSpec(t *testing.T) {
+	tests := []struct {
+		spec        string
+		source      string
+		destination string
+		ok          bool
+	}{
+		{"dist:/srv/app", "dist", "/srv/app", true},
+		{`C:\build\app.zip:/srv/app.zip`, `C:\build\app.zip`, "/srv/app.zip", true},
+		{"/var/log/app.log:D:/logs/app.log", "/var/log/app.log", "D:/logs/app.log", true},
+		{"C:/a:D:/b", "C:/a", "D:/b", true},
+		{"dist", "", "", false},
+		{"dist:", "", "", false},
+	}
+
+	// a:/x is the file a copied to /x unless it runs on Windows.
+	if runtime.GOOS != "windows" {
+		tests = append(tests, struct {
+			spec        string
+			source      string
+			destination string
+			ok          bool
+		}{"a:/x", "a", "/x", true})
+	}
+
+	for _, test := range tests {
+		source, destination, ok := splitFileSpec(test.spec)
+		assert.Equal(t, test.ok, ok, test.spec)
+		assert.Equal(t, test.source, source, test.spec)
+		assert.Equal(t, test.destination, destination, test.spec)
+	}
+}
+
+func TestScpSyncDirectory(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	local := t.TempDir()
+	remote := t.TempDir()
+
+	os.MkdirAll(filepath.Join(local, "dist", "js"), 0755)
+	os.WriteFile(filepath.Join(local, "dist", "index.html"), []byte("<html>"), 0644)
+	os.WriteFile(filepath.Join(local, "dist", "js", "app.js"), []byte("run()"), 0755)
+	os.WriteFile(filepath.Join(remote, "old.txt"), []byte("old"), 0644)
+
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Cwd = local
+	ctx.Data.With = map[string]interface{}{"delete": true}
+	files := []fileSpec{{source: "dist", destination: remote}}
+
+	stdout := &bytes.Buffer{}
+	err := runScpTarget(context.Background(), "upload", ctx, "app", ctx.Data.Hosts["app"], files, stdout)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout.String(), "2 copied, 0 unchanged, 1 deleted")
+
+	data, err := os.ReadFile(filepath.Join(remote, "js", "app.js"))
+	assert.NoError(t, err)
+	assert.Equal(t, "run()", string(data))
+
+	info, _ := os.Stat(filepath.Join(remote, "js", "app.js"))
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	assert.NoFileExists(t, filepath.Join(remote, "old.txt"))
+
+	// unchanged files are skipped on the next run.
+	stdout.Reset()
+	err = runScpTarget(context.Background(), "upload", ctx, "app", ctx.Data.Hosts["app"], files, stdout)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout.String(), "0 copied, 2 unchanged, 0 deleted")
+}
+
+func TestScpDownloadGlob(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	local := t.TempDir()
+	remote := t.TempDir()
+
+	os.MkdirAll(filepath.Join(remote, "logs", "2024"), 0755)
+	os.WriteFile(filepath.Join(remote, "logs", "app.log"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(remote, "logs", "2024", "app.log"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(remote, "logs", "app.txt"), []byte("c"), 0644)
+
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Cwd = local
+	ctx.Data.With = map[string]interface{}{"checksum": true}
+	files := []fileSpec{{source: remote + "/logs/**/*.log", destination: "logs"}}
+
+	stdout := &bytes.Buffer{}
+	err := runScpTarget(context.Background(), "download", ctx, "app", ctx.Data.Hosts["app"], files, stdout)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout.String(), "2 copied")
+
+	assert.FileExists(t, filepath.Join(local, "logs", "app.log"))
+	assert.FileExists(t, filepath.Join(local, "logs", "2024", "app.log"))
+	assert.NoFileExists(t, filepath.Join(local, "logs", "app.txt"))
+}
+
+// unlistableFS fails to list the files of the destination.
+type unlistableFS struct {
+	localFS
+}
+
+func (unlistableFS) Files(dir string) ([]string, error) {
+	return nil, os.ErrPermission
+}
+
+func TestSyncFilesDeleteFailsWhenFilesCannotBeListed(t *testing.T) {
+	local := t.TempDir()
+	remote := t.TempDir()
+	os.MkdirAll(filepath.Join(local, "dist"), 0755)
+	os.WriteFile(filepath.Join(local, "dist", "index.html"), []byte("<html>"), 0644)
+
+	src := localFS{dir: local}
+	dst := unlistableFS{localFS{dir: remote}}
+	spec := fileSpec{source: "dist", destination: filepath.Join(remote, "app")}
+
+	_, err := syncFiles(context.Background(), src, dst, spec, syncOptions{delete: true}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "Failed to list the files to delete in "+filepath.Join(remote, "app"))
+
+	_, err = syncFiles(context.Background(), src, dst, spec, syncOptions{}, &bytes.Buffer{})
+	assert.NoError(t, err)
+}
@@ -1,8 +1,11 @@
 package tasks
 
 import (
+	"context"
 	"runtime"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/hyprxlabs/go/exec"
 
@@ -10,6 +13,10 @@ import (
 	"github.com/hyprxlabs/xtask/shells"
 )
 
+// killGracePeriod is the time a cancelled shell task has to exit after
+// receiving SIGTERM before it is killed.
+const killGracePeriod = 5 * time.Second
+
 func runShell(ctx TaskContext) *TaskResult {
 	res := NewTaskResult()
 	if ctx.Data.Uses == "" {
@@ -29,37 +36,47 @@ func runShell(ctx TaskContext) *TaskResult {
 	var cmd *exec.Cmd
 
 	run := ctx.Data.Run
-	splat := ctx.Task.Args
+	// the args of the command line follow the args of the task.
+	splat := append(slices.Clone(ctx.Task.Args), ctx.Args...)
+
+	unsupported := false
+	withTaskEnv(&ctx.Data.Env, func() {
+		switch ctx.Data.Uses {
+		case "bash":
+			cmd = shells.BashScriptContext(ctx.Context, run, splat...)
 
-	switch ctx.Data.Uses {
-	case "bash":
-		cmd = shells.BashScriptContext(ctx.Context, run, splat...)
+		case "powershell":
+			cmd = shells.PowerShellScriptContext(ctx.Context, run, splat...)
 
-	case "powershell":
-		cmd = shells.PowerShellScriptContext(ctx.Context, run, splat...)
+		case "sh":
+			cmd = shells.ShScriptContext(ctx.Context, run, splat...)
 
-	case "sh":
-		cmd = shells.ShScriptContext(ctx.Context, run, splat...)
+		case "pwsh":
+			cmd = shells.PwshScriptContext(ctx.Context, run, splat...)
 
-	case "pwsh":
-		cmd = shells.PwshScriptContext(ctx.Context, run, splat...)
+		case "deno":
+			cmd = shells.DenoScriptContext(ctx.Context, run, splat...)
 
-	case "deno":
-		cmd = shells.DenoScriptContext(ctx.Context, run, splat...)
+		case "node":
+			cmd = shells.NodeScriptContext(ctx.Context, run, splat...)
 
-	case "node":
-		cmd = shells.NodeScriptContext(ctx.Context, run, splat...)
+		case "bun":
+			cmd = shells.BunScriptContext(ctx.Context, run, splat...)
 
-	case "bun":
-		cmd = shells.BunScriptContext(ctx.Context, run, splat...)
+		case "python":
+			cmd = shells.PythonScriptContext(ctx.Context, run, splat...)
 
-	case "python":
-		cmd = shells.PythonScriptContext(ctx.Context, run, splat...)
+		case "ruby":
+			cmd = shells.RubyScriptContext(ctx.Context, run, splat...)
 
-	case "ruby":
-		cmd = shells.RubyScriptContext(ctx.Context, run, splat...)
+		default:
+			unsupported = true
+		}
 
-	default:
+		resolveCmd(cmd)
+	})
+
+	if unsupported {
 		err := errors.New("Unsupported shell: " + ctx.Data.Uses)
 		return res.Fail(err)
 	}
@@ -72,8 +89,19 @@ func runShell(ctx TaskContext) *TaskResult {
 		cmd.WithEnvMap(ctx.Data.Env.ToMap())
 	}
 
+	waited := setProcessGroup(cmd, killGracePeriod)
+
 	res.Start()
 	o, err := cmd.Run()
+	waited()
+	if ctxErr := ctx.Context.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return res.Cancel("Task " + ctx.Task.Id + " timed out after " + ctx.Data.Timeout.String())
+		}
+
+		return res.Cancel("Task " + ctx.Task.Id + " cancelled")
+	}
+
 	if err != nil {
 		return res.Fail(err)
 	}
@@ -2,14 +2,13 @@ package tasks
 
 import (
 	"context"
-	"net"
+	"io"
 	"net/url"
-	"os"
-	"strconv"
+	"slices"
+	"strings"
 
 	"github.com/hyprxlabs/xtask/errors"
 	"github.com/hyprxlabs/xtask/types"
-	goph "github.com/melbahja/goph"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -31,176 +30,159 @@ func runSSH(ctx TaskContext) *TaskResult {
 		return res.Fail(errors.New("Invalid SSH URI scheme: " + uri.Scheme))
 	}
 
-	targets := []types.Host{}
+	targets := []hostTarget{}
 	if uri.Host != "" {
-		user := ""
-		if uri.User != nil {
-			user = uri.User.Username()
-		}
-
-		port := 22
-		if uri.Port() != "" {
-			port, err = strconv.Atoi(uri.Port())
-			if err != nil {
-				return res.Fail(errors.New("Invalid port in SSH URI: " + err.Error()))
-			}
-		}
-
-		password, ok := uri.User.Password()
-		if ok && password != "" {
-			p, ok := ctx.Data.Env.Get(password)
-			if ok {
-				password = p
-			}
+		target, err := uriHostTarget(uri, ctx.Inventory)
+		if err != nil {
+			return res.Fail(err)
 		}
 
-		identity := uri.Query().Get("identity")
-		targets = append(targets, types.Host{
-			Host:     uri.Host,
-			User:     &user,
-			Port:     &port,
-			Identity: &identity,
-			Password: &password,
-		})
-	} else {
-		for _, t := range ctx.Data.Hosts {
-			targets = append(targets, types.Host{
-				Host:     t.Host,
-				User:     t.User,
-				Port:     t.Port,
-				Identity: t.Identity,
-				Password: t.Password,
-			})
-		}
+		targets = append(targets, target)
+	} else if len(ctx.Data.Hosts) > 0 {
+		targets = hostTargets(ctx.Data.Hosts)
 	}
 
 	if len(targets) == 0 {
 		return res.Fail(errors.New("No targets found for SSH task"))
 	}
 
-	for _, target := range targets {
-		err := runSSHTarget(ctx.Context, ctx, target)
-		if errors.Is(err, context.Canceled) {
-			return res.Cancel("Task " + ctx.Task.Id + " cancelled")
-		}
-
-		if errors.Is(err, context.DeadlineExceeded) {
-			return res.Cancel("Task " + ctx.Task.Id + " cancelled due to timeout")
-		}
-
-		if err != nil {
-			return res.Fail(errors.New("Failed to run SSH task on target " + target.Host + ": " + err.Error()))
-		}
-	}
-
-	res.End()
-
-	// Placeholder for running an SSH command
-	// This would typically involve executing the command over SSH
-	return res.Ok()
+	return runOnHosts(ctx, targets, func(c context.Context, target hostTarget, stdout io.Writer, stderr io.Writer) (int, error) {
+		return runSSHTarget(c, ctx, target.alias, target.host, stdout, stderr)
+	})
 }
 
 type SshRun struct {
+	Code  int
 	Error error
 }
 
-func runSSHTarget(ctx context.Context, taskContext TaskContext, target types.Host) error {
-	signal := make(chan SshRun)
+// runSSHTarget runs the script of the task on target and returns the exit
+// code of the script, or -1 when the script did not run.
+func runSSHTarget(ctx context.Context, taskContext TaskContext, alias string, target types.Host, stdout io.Writer, stderr io.Writer) (int, error) {
+	// buffered so the session goroutine can exit when the
+	// context is done before the command completes.
+	signal := make(chan SshRun, 1)
 
-	var auth goph.Auth
-	var err error
-	identity := ""
-	password := ""
-	run := ""
-	if target.Identity != nil {
-		identity = *target.Identity
+	become, err := becomeFor(taskContext.Task, target, &taskContext.Data.Env)
+	if err != nil {
+		return -1, err
 	}
 
-	if target.Password != nil {
-		password = *target.Password
+	client, release, err := connectSSH(taskContext, alias, target)
+	if err != nil {
+		return -1, err
 	}
 
-	run = taskContext.Data.Run
+	defer release()
 
-	if identity == "" && password != "" {
-		auth = goph.Password(password)
-	} else if goph.HasAgent() {
-		auth, err = goph.UseAgent()
-	} else if identity != "" {
-		auth, err = goph.Key(identity, password)
-	} else {
-		return errors.New("No authentication method provided for SSH task")
-	}
+	run := taskContext.Data.Run
 
-	if err != nil {
-		signal <- SshRun{Error: errors.New("Failed to create SSH authentication: " + err.Error())}
-	}
+	var sess *ssh.Session
 
-	port := 22
-	if target.Port != nil && *target.Port > 0 {
-		port = int(*target.Port)
-	}
-	user := ""
-	if target.User != nil && *target.User != "" {
-		user = *target.User
+	if sess, err = client.NewSession(); err != nil {
+		err2 := errors.New("Failed to create SSH session: " + err.Error())
+		return -1, err2
 	}
 
-	client, err := goph.NewConn(&goph.Config{
-		User: user,
-		Addr: target.Host,
-		Port: uint(port),
-		Auth: auth,
-		Callback: func(host string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		},
-	})
+	defer sess.Close()
 
-	if err != nil {
-		err2 := errors.New("Failed to connect to SSH target " + target.Host + ": " + err.Error())
-		err2 = errors.WithCause(err2, err)
-		return err2
+	if shell := withString(taskContext.Data.With, "shell"); shell != "" {
+		cmd, cleanup, err := uploadScript(client, taskContext, shell, run, become)
+		if err != nil {
+			return -1, err
+		}
+
+		defer cleanup()
+		run = cmd
 	}
 
-	defer client.Close()
+	flush := func() {}
+	if become != nil {
+		env := map[string]string{}
+		keys := remoteEnvKeys(taskContext)
+		for _, key := range keys {
+			env[key], _ = taskContext.Data.Env.Get(key)
+		}
 
-	var sess *ssh.Session
+		var pty bool
+		run, pty = become.command(run, env, keys)
+		if pty {
+			modes := ssh.TerminalModes{ssh.ECHO: 0, ssh.TTY_OP_ISPEED: 14400, ssh.TTY_OP_OSPEED: 14400}
+			if err := sess.RequestPty("xterm", 40, 200, modes); err != nil {
+				return -1, errors.New("Failed to request a pty for " + become.method + ": " + err.Error())
+			}
+		}
 
-	if sess, err = client.NewSession(); err != nil {
-		err2 := errors.New("Failed to create SSH session: " + err.Error())
-		return err2
-	}
+		if become.password != "" {
+			stdin, err := sess.StdinPipe()
+			if err != nil {
+				return -1, errors.New("Failed to open stdin of the SSH session: " + err.Error())
+			}
 
-	defer sess.Close()
+			answer := becomeAnswer(stdin, become.password)
+			out := newBecomeWriter(stdout, become, answer)
+			errOut := newBecomeWriter(stderr, become, answer)
+			stdout = out
+			stderr = errOut
+			flush = func() {
+				out.Flush()
+				errOut.Flush()
+			}
+		}
+	}
 
 	go func() {
 
 		if taskContext.Data.Env.Len() > 0 {
 			// only set env values that are explicitly set in the task
-			for _, key := range taskContext.Task.Env.Keys() {
+			for _, key := range remoteEnvKeys(taskContext) {
 				value, _ := taskContext.Data.Env.Get(key)
 				sess.Setenv(key, value)
 			}
 		}
 
-		sess.Stdout = os.Stdout
-		sess.Stderr = os.Stderr
-		err = sess.Run(run)
+		sess.Stdout = stdout
+		sess.Stderr = stderr
+		err := sess.Run(run)
+		flush()
 
 		if err != nil {
+			code := -1
+			var exitErr *ssh.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitStatus()
+			}
+
 			err2 := errors.New("Failed to run command on SSH target " + target.Host + ": " + err.Error())
 			err2 = errors.WithCause(err2, err)
-			signal <- SshRun{Error: err2}
+			signal <- SshRun{Code: code, Error: err2}
 			return
 		}
 
-		signal <- SshRun{Error: nil}
+		signal <- SshRun{Code: 0, Error: nil}
 	}()
 
 	select {
 	case <-ctx.Done():
 		sess.Signal(ssh.SIGINT)
-		return ctx.Err()
+		return -1, ctx.Err()
 	case result := <-signal:
-		return result.Error
+		return result.Code, result.Error
 	}
 }
+
+// remoteEnvKeys returns the env vars that are sent to the host: the env of
+// the task with the params and the inputs of shared tasks, which local
+// tasks read from their env as well.
+func remoteEnvKeys(ctx TaskContext) []string {
+	keys := ctx.Task.Env.Keys()
+	for _, key := range ctx.Data.Env.Keys() {
+		if strings.HasPrefix(key, "PARAM_") || strings.HasPrefix(key, "INPUT_") {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,355 @@
+package tasks
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/types"
+	goph "github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// HostKeyCheckStrict only accepts hosts whose key is in known_hosts.
+	HostKeyCheckStrict = "strict"
+	// HostKeyCheckAcceptNew adds the key of unknown hosts to known_hosts
+	// and rejects hosts whose key changed.
+	HostKeyCheckAcceptNew = "accept-new"
+	// HostKeyCheckInsecure accepts any host key.
+	HostKeyCheckInsecure = "insecure"
+)
+
+// knownHostsMu serializes writes to known_hosts files when hosts are
+// accepted by concurrent tasks.
+var knownHostsMu sync.Mutex
+
+// newSSHClient connects to target using the identity, password or the
+// ssh agent and verifies the host key of target. The ~/.ssh/config
+// defaults of the host are applied and the connection goes through the
+// proxy jumps of the host.
+func newSSHClient(alias string, target types.Host, e *types.Env, inventory types.Hosts) (*goph.Client, error) {
+	route, err := sshRoute(alias, target, e, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	var client *goph.Client
+	for i, hop := range route {
+		config, err := sshClientConfig(hop, e)
+		if err == nil {
+			client, err = dialSSH(client, config)
+		} else if client != nil {
+			client.Close()
+		}
+
+		if err != nil {
+			err2 := errors.New("Failed to connect to SSH target " + hop.Host + ": " + err.Error())
+			if i < len(route)-1 {
+				err2 = errors.New("Failed to connect to SSH target " + route[len(route)-1].Host + " through " + hop.Host + ": " + err.Error())
+			}
+
+			err2 = errors.WithCause(err2, err)
+			return nil, err2
+		}
+	}
+
+	return client, nil
+}
+
+// sshClientConfig returns the connection settings of a single host.
+func sshClientConfig(target types.Host, e *types.Env) (*goph.Config, error) {
+	var auth goph.Auth
+	var err error
+	identity := ""
+	password := ""
+	if target.Identity != nil {
+		identity = expandHome(*target.Identity, e)
+	}
+
+	// the password is the name of the env var that holds the password.
+	if target.Password != nil {
+		password = *target.Password
+		if password != "" {
+			if p, ok := e.Get(password); ok {
+				password = p
+			}
+		}
+	}
+
+	if identity == "" && password != "" {
+		auth = goph.Password(password)
+	} else if goph.HasAgent() {
+		auth, err = goph.UseAgent()
+	} else if identity != "" {
+		auth, err = goph.Key(identity, password)
+	} else {
+		return nil, errors.New("No authentication method provided for SSH task")
+	}
+
+	if err != nil {
+		return nil, errors.New("Failed to create SSH authentication: " + err.Error())
+	}
+
+	callback, err := hostKeyCallback(target, e)
+	if err != nil {
+		return nil, err
+	}
+
+	port := 22
+	if target.Port != nil && *target.Port > 0 {
+		port = int(*target.Port)
+	}
+	user := ""
+	if target.User != nil && *target.User != "" {
+		user = *target.User
+	}
+
+	return &goph.Config{
+		User:     user,
+		Addr:     target.Host,
+		Port:     uint(port),
+		Auth:     auth,
+		Callback: callback,
+	}, nil
+}
+
+// dialSSH connects to the host of config, through the via connection when
+// it is not nil. The via connection is closed when the new connection is
+// closed or fails.
+func dialSSH(via *goph.Client, config *goph.Config) (*goph.Client, error) {
+	if via == nil {
+		return goph.NewConn(config)
+	}
+
+	addr := net.JoinHostPort(config.Addr, strconv.Itoa(int(config.Port)))
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		via.Close()
+		return nil, err
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            config.User,
+		Auth:            config.Auth,
+		Timeout:         config.Timeout,
+		HostKeyCallback: config.Callback,
+	})
+	if err != nil {
+		conn.Close()
+		via.Close()
+		return nil, err
+	}
+
+	client := &goph.Client{Client: ssh.NewClient(c, chans, reqs), Config: config}
+	go func() {
+		client.Wait()
+		via.Close()
+	}()
+
+	return client, nil
+}
+
+// uriHostTarget returns the host of an ssh:// or scp:// uri. The host of
+// the uri may be an alias of the inventory, whose settings are used for
+// the parts that the uri does not set.
+func uriHostTarget(uri *url.URL, inventory types.Hosts) (hostTarget, error) {
+	alias := uri.Hostname()
+	host, ok := inventory[alias]
+	if !ok {
+		host = types.Host{Host: alias}
+	}
+
+	if uri.User != nil {
+		if user := uri.User.Username(); user != "" {
+			host.User = &user
+		}
+
+		// the password is the name of the env var that holds the password.
+		if password, ok := uri.User.Password(); ok && password != "" {
+			host.Password = &password
+		}
+	}
+
+	if uri.Port() != "" {
+		port, err := strconv.Atoi(uri.Port())
+		if err != nil {
+			return hostTarget{}, errors.New("Invalid port in SSH URI: " + err.Error())
+		}
+		host.Port = &port
+	}
+
+	if identity := queryValue(uri, "identity"); identity != nil {
+		host.Identity = identity
+	}
+
+	if check := queryValue(uri, "host-key-check"); check != nil {
+		host.HostKeyCheck = check
+	}
+
+	if knownHosts := queryValue(uri, "known-hosts"); knownHosts != nil {
+		host.KnownHosts = knownHosts
+	}
+
+	if jump := queryValue(uri, "proxy-jump"); jump != nil {
+		host.ProxyJump = jump
+	}
+
+	return hostTarget{alias: alias, host: host}, nil
+}
+
+// hostKeyCallback returns the callback that verifies the host key of
+// target. The mode and known_hosts files of the host are used when set,
+// otherwise XTASK_SSH_HOST_KEY_CHECK and XTASK_SSH_KNOWN_HOSTS, which may
+// list several files, and then strict with ~/.ssh/known_hosts. Insecure
+// must be set on the host itself, so that one env var or config value
+// cannot turn off the checks for every host.
+func hostKeyCallback(target types.Host, e *types.Env) (ssh.HostKeyCallback, error) {
+	mode := e.GetString("XTASK_SSH_HOST_KEY_CHECK")
+	if mode == HostKeyCheckInsecure {
+		return nil, errors.New("Invalid XTASK_SSH_HOST_KEY_CHECK or config.ssh.host-key-check for " + target.Host + ": insecure may only be set with the host-key-check of a host")
+	}
+
+	if target.HostKeyCheck != nil && *target.HostKeyCheck != "" {
+		mode = *target.HostKeyCheck
+	}
+
+	if mode == "" {
+		mode = HostKeyCheckStrict
+	}
+
+	switch mode {
+	case HostKeyCheckInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case HostKeyCheckStrict, HostKeyCheckAcceptNew:
+	default:
+		return nil, errors.New("Invalid host-key-check for " + target.Host + ": " + mode + ", expected strict, accept-new or insecure")
+	}
+
+	files := []string{}
+	if target.KnownHosts != nil && *target.KnownHosts != "" {
+		files = append(files, expandHome(*target.KnownHosts, e))
+	} else if value := e.GetString("XTASK_SSH_KNOWN_HOSTS"); value != "" {
+		for _, file := range filepath.SplitList(value) {
+			if file != "" {
+				files = append(files, expandHome(file, e))
+			}
+		}
+	} else {
+		files = append(files, expandHome("~/.ssh/known_hosts", e))
+	}
+
+	existing := []string{}
+	for _, file := range files {
+		if isFile(file) {
+			existing = append(existing, file)
+		}
+	}
+
+	if len(existing) == 0 && mode == HostKeyCheckStrict {
+		return nil, errors.New("Cannot verify the host key of " + target.Host + ": no known_hosts file found at " + strings.Join(files, ", ") + ". Add the host to known_hosts or set host-key-check to accept-new")
+	}
+
+	var check ssh.HostKeyCallback
+	if len(existing) > 0 {
+		cb, err := knownhosts.New(existing...)
+		if err != nil {
+			return nil, errors.New("Failed to read known_hosts: " + err.Error())
+		}
+		check = cb
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := errors.New("host key for " + hostname + " is unknown")
+		if check != nil {
+			err = check(hostname, remote, key)
+			if err == nil {
+				return nil
+			}
+		}
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+			return errors.New("Host key verification failed for " + hostname + ": the key does not match the key in " + keyErr.Want[0].Filename + ". The host key may have changed or the connection may be intercepted")
+		}
+
+		if mode != HostKeyCheckAcceptNew {
+			if keyErr != nil || check == nil {
+				return errors.New("Host key verification failed for " + hostname + ": the host is not in known_hosts. Add the host to known_hosts or set host-key-check to accept-new")
+			}
+
+			return err
+		}
+
+		return appendKnownHost(files[0], hostname, remote, key)
+	}, nil
+}
+
+// appendKnownHost adds the key of a host to a known_hosts file.
+func appendKnownHost(file string, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return errors.New("Failed to create known_hosts directory: " + err.Error())
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.New("Failed to open known_hosts: " + err.Error())
+	}
+	defer f.Close()
+
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if remote != nil {
+		if addr := knownhosts.Normalize(remote.String()); addr != addresses[0] {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	if _, err := f.WriteString(knownhosts.Line(addresses, key) + "\n"); err != nil {
+		return errors.New("Failed to write known_hosts: " + err.Error())
+	}
+
+	os.Stderr.WriteString("Added " + addresses[0] + " (" + key.Type() + ") to " + file + "\n")
+	return nil
+}
+
+// queryValue returns the value of key in the query of uri or nil when it
+// is not set.
+func queryValue(uri *url.URL, key string) *string {
+	value := uri.Query().Get(key)
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func expandHome(path string, e *types.Env) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~\\") {
+		return path
+	}
+
+	home := e.GetString("HOME")
+	if home == "" {
+		home = e.GetString("USERPROFILE")
+	}
+
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+
+	return filepath.Join(home, path[1:])
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
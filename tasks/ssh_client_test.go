@@ -0,0 +1,98 @@
+package tasks
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+	key := newTestHostKey(t)
+
+	e := types.NewEnv()
+	e.Set("XTASK_SSH_KNOWN_HOSTS", knownHosts)
+
+	mode := func(m string) *string { return &m }
+	host := types.Host{Host: "web1"}
+
+	// strict requires a known_hosts file.
+	_, err := hostKeyCallback(host, e)
+	assert.Error(t, err)
+
+	host.HostKeyCheck = mode(HostKeyCheckAcceptNew)
+	cb, err := hostKeyCallback(host, e)
+	assert.NoError(t, err)
+	assert.NoError(t, cb("web1:22", remote, key))
+	assert.FileExists(t, knownHosts)
+
+	host.HostKeyCheck = nil
+	cb, err = hostKeyCallback(host, e)
+	assert.NoError(t, err)
+	assert.NoError(t, cb("web1:22", remote, key))
+
+	// a changed key is rejected, even with accept-new.
+	other := newTestHostKey(t)
+	assert.ErrorContains(t, cb("web1:22", remote, other), "does not match")
+
+	host.HostKeyCheck = mode(HostKeyCheckAcceptNew)
+	cb, err = hostKeyCallback(host, e)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, cb("web1:22", remote, other), "does not match")
+
+	// unknown hosts are rejected by strict.
+	host.HostKeyCheck = nil
+	cb, err = hostKeyCallback(host, e)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, cb("web2:22", remote, other), "not in known_hosts")
+
+	host.HostKeyCheck = mode(HostKeyCheckInsecure)
+	cb, err = hostKeyCallback(host, e)
+	assert.NoError(t, err)
+	assert.NoError(t, cb("web2:22", remote, other))
+
+	host.HostKeyCheck = mode("yes")
+	_, err = hostKeyCallback(host, e)
+	assert.Error(t, err)
+
+	// insecure is refused from the env, even for a host that sets a mode.
+	e.Set("XTASK_SSH_HOST_KEY_CHECK", HostKeyCheckInsecure)
+	host.HostKeyCheck = nil
+	_, err = hostKeyCallback(host, e)
+	assert.ErrorContains(t, err, "insecure may only be set with the host-key-check of a host")
+	host.HostKeyCheck = mode(HostKeyCheckInsecure)
+	_, err = hostKeyCallback(host, e)
+	assert.Error(t, err)
+}
+
+func TestHostKeyCallbackHostKnownHosts(t *testing.T) {
+	dir := t.TempDir()
+	knownHosts := filepath.Join(dir, "hosts")
+	key := newTestHostKey(t)
+	line := "db1 " + string(ssh.MarshalAuthorizedKey(key))
+	assert.NoError(t, os.WriteFile(knownHosts, []byte(line), 0600))
+
+	e := types.NewEnv()
+	e.Set("XTASK_SSH_KNOWN_HOSTS", filepath.Join(dir, "missing"))
+	host := types.Host{Host: "db1", KnownHosts: &knownHosts}
+
+	cb, err := hostKeyCallback(host, e)
+	assert.NoError(t, err)
+	assert.NoError(t, cb("db1:22", &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 22}, key))
+}
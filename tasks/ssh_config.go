@@ -0,0 +1,463 @@
+package tasks
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// maxProxyJumps limits the number of jumps used to reach a host, which
+// also stops hosts that jump through each other.
+const maxProxyJumps = 8
+
+// sshConfig holds the Host and Match blocks of an ssh config file. Only
+// the options used by xtask are kept.
+type sshConfig struct {
+	blocks []*sshConfigBlock
+}
+
+type sshConfigBlock struct {
+	patterns []string
+	// all is true for the options before the first Host and for Match all.
+	all     bool
+	options [][2]string
+}
+
+// sshHostConfig holds the options of an ssh config file for a host.
+type sshHostConfig struct {
+	HostName      string
+	User          string
+	Port          int
+	IdentityFiles []string
+	ProxyJump     string
+}
+
+// loadSSHConfig reads XTASK_SSH_CONFIG or ~/.ssh/config. A missing file
+// and none return an empty config.
+func loadSSHConfig(e *types.Env) (*sshConfig, error) {
+	file := e.GetString("XTASK_SSH_CONFIG")
+	if strings.EqualFold(file, "none") {
+		return &sshConfig{}, nil
+	}
+
+	if file == "" {
+		file = "~/.ssh/config"
+	}
+
+	file = expandHome(file, e)
+	if !isFile(file) {
+		return &sshConfig{}, nil
+	}
+
+	config := &sshConfig{}
+	if err := config.parseFile(file, &sshConfigBlock{all: true}, e, 0); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *sshConfig) parseFile(file string, block *sshConfigBlock, e *types.Env, depth int) error {
+	if depth > 16 {
+		return errors.New("Too many nested includes in ssh config " + file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.New("Failed to read ssh config " + file + ": " + err.Error())
+	}
+	defer f.Close()
+
+	return c.parse(f, file, block, e, depth)
+}
+
+func (c *sshConfig) parse(r io.Reader, file string, block *sshConfigBlock, e *types.Env, depth int) error {
+	c.blocks = append(c.blocks, block)
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, args := splitSSHConfigLine(line)
+		if len(args) == 0 {
+			return errors.New("Invalid line " + strconv.Itoa(n) + " in ssh config " + file + ": " + line)
+		}
+
+		switch strings.ToLower(key) {
+		case "host":
+			block = &sshConfigBlock{patterns: args}
+			c.blocks = append(c.blocks, block)
+		case "match":
+			// only match all is supported, other criteria never match.
+			block = &sshConfigBlock{all: len(args) == 1 && strings.EqualFold(args[0], "all")}
+			c.blocks = append(c.blocks, block)
+		case "include":
+			for _, pattern := range args {
+				pattern = expandHome(pattern, e)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(expandHome("~/.ssh", e), pattern)
+				}
+
+				matches, _ := filepath.Glob(pattern)
+				for _, match := range matches {
+					// the included file continues the current block.
+					next := &sshConfigBlock{patterns: block.patterns, all: block.all}
+					if err := c.parseFile(match, next, e, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+
+			block = &sshConfigBlock{patterns: block.patterns, all: block.all}
+			c.blocks = append(c.blocks, block)
+		default:
+			block.options = append(block.options, [2]string{strings.ToLower(key), strings.Join(args, " ")})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.New("Failed to read ssh config " + file + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// lookup returns the options for host. As with ssh, the first value of an
+// option wins, except for IdentityFile which may be set several times.
+func (c *sshConfig) lookup(host string) sshHostConfig {
+	result := sshHostConfig{}
+	seen := map[string]bool{}
+	for _, block := range c.blocks {
+		if !block.all && !matchSSHPatterns(block.patterns, host) {
+			continue
+		}
+
+		for _, option := range block.options {
+			key, value := option[0], option[1]
+			if key == "identityfile" {
+				result.IdentityFiles = append(result.IdentityFiles, value)
+				continue
+			}
+
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			switch key {
+			case "hostname":
+				result.HostName = value
+			case "user":
+				result.User = value
+			case "port":
+				result.Port, _ = strconv.Atoi(value)
+			case "proxyjump":
+				result.ProxyJump = value
+			}
+		}
+	}
+
+	return result
+}
+
+// merge returns the options of c with the options that c does not set taken
+// from other. The identity files of c are tried first.
+func (c sshHostConfig) merge(other sshHostConfig) sshHostConfig {
+	if c.HostName == "" {
+		c.HostName = other.HostName
+	}
+
+	if c.User == "" {
+		c.User = other.User
+	}
+
+	if c.Port == 0 {
+		c.Port = other.Port
+	}
+
+	if c.ProxyJump == "" {
+		c.ProxyJump = other.ProxyJump
+	}
+
+	c.IdentityFiles = append(c.IdentityFiles, other.IdentityFiles...)
+	return c
+}
+
+// applySSHConfig fills the fields of target that are not set from the ssh
+// config. The options are looked up by the inventory alias first and then
+// by the host name of target.
+func applySSHConfig(alias string, target types.Host, e *types.Env) (types.Host, error) {
+	config, err := loadSSHConfig(e)
+	if err != nil {
+		return target, err
+	}
+
+	name := target.Host
+	options := config.lookup(name)
+	if alias != "" && alias != target.Host {
+		aliasOptions := config.lookup(alias)
+		if aliasOptions.HostName != "" {
+			name = alias
+		}
+		options = aliasOptions.merge(options)
+	}
+
+	if options.HostName != "" {
+		target.Host = expandSSHTokens(options.HostName, name, "", 0, e)
+	}
+
+	if (target.User == nil || *target.User == "") && options.User != "" {
+		target.User = &options.User
+	}
+
+	if (target.Port == nil || *target.Port == 0) && options.Port > 0 {
+		target.Port = &options.Port
+	}
+
+	if (target.ProxyJump == nil || *target.ProxyJump == "") && options.ProxyJump != "" {
+		target.ProxyJump = &options.ProxyJump
+	}
+
+	if target.Identity == nil || *target.Identity == "" {
+		user := ""
+		if target.User != nil {
+			user = *target.User
+		}
+
+		port := 22
+		if target.Port != nil && *target.Port > 0 {
+			port = *target.Port
+		}
+
+		for _, file := range options.IdentityFiles {
+			file = expandHome(expandSSHTokens(file, target.Host, user, port, e), e)
+			if isFile(file) {
+				target.Identity = &file
+				break
+			}
+		}
+	}
+
+	return target, nil
+}
+
+// sshRoute returns the hosts to connect through to reach target, ending
+// with target. Each host is resolved with the ssh config and the jumps
+// may refer to the aliases of the inventory. alias is the inventory alias
+// of target, if any.
+func sshRoute(alias string, target types.Host, e *types.Env, inventory types.Hosts) ([]types.Host, error) {
+	var resolve func(alias string, host types.Host, depth int) ([]types.Host, error)
+	resolve = func(alias string, host types.Host, depth int) ([]types.Host, error) {
+		if depth > maxProxyJumps {
+			return nil, errors.New("Too many proxy jumps to reach " + target.Host + ", the jump hosts may refer to each other")
+		}
+
+		host, err := applySSHConfig(alias, host, e)
+		if err != nil {
+			return nil, err
+		}
+
+		route := []types.Host{}
+		if host.ProxyJump != nil && !strings.EqualFold(*host.ProxyJump, "none") {
+			for _, spec := range strings.Split(*host.ProxyJump, ",") {
+				spec = strings.TrimSpace(spec)
+				if spec == "" {
+					continue
+				}
+
+				jump, err := parseJumpHost(spec, inventory)
+				if err != nil {
+					return nil, err
+				}
+
+				jumpAlias := ""
+				if _, ok := inventory[spec]; ok {
+					jumpAlias = spec
+				}
+
+				hops, err := resolve(jumpAlias, jump, depth+1)
+				if err != nil {
+					return nil, err
+				}
+
+				route = append(route, hops...)
+			}
+		}
+
+		return append(route, host), nil
+	}
+
+	return resolve(alias, target, 0)
+}
+
+// parseJumpHost returns the host of an inventory alias or of a jump in the
+// form [ssh://][user@]host[:port].
+func parseJumpHost(spec string, inventory types.Hosts) (types.Host, error) {
+	if host, ok := inventory[spec]; ok {
+		return host, nil
+	}
+
+	host := types.Host{}
+	rest := strings.TrimPrefix(spec, "ssh://")
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		user := rest[:i]
+		host.User = &user
+		rest = rest[i+1:]
+	}
+
+	if name, port, err := net.SplitHostPort(rest); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return host, errors.New("Invalid port in proxy-jump " + spec)
+		}
+
+		host.Port = &p
+		rest = name
+	}
+
+	if rest == "" {
+		return host, errors.New("Invalid proxy-jump " + spec)
+	}
+
+	host.Host = rest
+	return host, nil
+}
+
+// matchSSHPatterns reports whether host matches the patterns of a Host
+// line. A match of a negated pattern excludes the host.
+func matchSSHPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, list := range patterns {
+		for _, pattern := range strings.Split(list, ",") {
+			if strings.HasPrefix(pattern, "!") {
+				if matchWildcard(strings.ToLower(pattern[1:]), strings.ToLower(host)) {
+					return false
+				}
+				continue
+			}
+
+			if matchWildcard(strings.ToLower(pattern), strings.ToLower(host)) {
+				matched = true
+			}
+		}
+	}
+
+	return matched
+}
+
+// matchWildcard matches s against a pattern where * matches any number of
+// characters and ? matches a single character.
+func matchWildcard(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchWildcard(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+
+	return len(s) == 0
+}
+
+// expandSSHTokens replaces the %h, %r, %p, %u, %d and %% tokens of an ssh
+// config value.
+func expandSSHTokens(value string, host string, remoteUser string, port int, e *types.Env) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+
+	sb := &strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i+1 == len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case 'h':
+			sb.WriteString(host)
+		case 'r':
+			sb.WriteString(remoteUser)
+		case 'p':
+			sb.WriteString(strconv.Itoa(port))
+		case 'd':
+			sb.WriteString(expandHome("~", e))
+		case 'u':
+			if u, err := user.Current(); err == nil {
+				sb.WriteString(u.Username)
+			}
+		case '%':
+			sb.WriteByte('%')
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(value[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// splitSSHConfigLine splits a line into the keyword and its arguments. The
+// keyword may be followed by = and arguments may be quoted.
+func splitSSHConfigLine(line string) (string, []string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, nil
+	}
+
+	key := line[:i]
+	rest := strings.TrimLeft(line[i:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	args := []string{}
+	sb := &strings.Builder{}
+	quoted := false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t') && !quoted:
+			if sb.Len() > 0 {
+				args = append(args, sb.String())
+				sb.Reset()
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	if sb.Len() > 0 {
+		args = append(args, sb.String())
+	}
+
+	return key, args
+}
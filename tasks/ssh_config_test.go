@@ -0,0 +1,243 @@
+package tasks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSSHConfig(t *testing.T, dir string, content string) *types.Env {
+	file := filepath.Join(dir, "config")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := types.NewEnv()
+	e.Set("HOME", dir)
+	e.Set("XTASK_SSH_CONFIG", file)
+	return e
+}
+
+func TestSSHConfigLookup(t *testing.T) {
+	dir := t.TempDir()
+	// relative includes are read from ~/.ssh
+	os.MkdirAll(filepath.Join(dir, ".ssh"), 0700)
+	os.WriteFile(filepath.Join(dir, ".ssh", "extra"), []byte("Host db\n  HostName 10.0.0.9\n"), 0600)
+	e := writeSSHConfig(t, dir, `
+# defaults before the first host apply to every host
+Port 2200
+
+Host web-* !web-test
+  HostName %h.example.com
+  User deploy
+  ProxyJump bastion
+
+Host web-1
+  User ignored
+
+Include extra
+
+Host *
+  User=fallback
+  IdentityFile "~/keys/id_%r"
+`)
+
+	config, err := loadSSHConfig(e)
+	assert.NoError(t, err)
+
+	web := config.lookup("web-1")
+	assert.Equal(t, "%h.example.com", web.HostName)
+	assert.Equal(t, "deploy", web.User)
+	assert.Equal(t, 2200, web.Port)
+	assert.Equal(t, "bastion", web.ProxyJump)
+	assert.Equal(t, []string{"~/keys/id_%r"}, web.IdentityFiles)
+
+	test := config.lookup("web-test")
+	assert.Equal(t, "", test.HostName)
+	assert.Equal(t, "fallback", test.User)
+
+	db := config.lookup("db")
+	assert.Equal(t, "10.0.0.9", db.HostName)
+}
+
+func TestApplySSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "keys"), 0700)
+	os.WriteFile(filepath.Join(dir, "keys", "id_deploy"), []byte("key"), 0600)
+	e := writeSSHConfig(t, dir, `
+Host web
+  HostName %h.example.com
+  User deploy
+  Port 2222
+  IdentityFile ~/keys/id_missing
+  IdentityFile ~/keys/id_%r
+`)
+
+	host, err := applySSHConfig("", types.Host{Host: "web"}, e)
+	assert.NoError(t, err)
+	assert.Equal(t, "web.example.com", host.Host)
+	assert.Equal(t, "deploy", *host.User)
+	assert.Equal(t, 2222, *host.Port)
+	assert.Equal(t, filepath.Join(dir, "keys", "id_deploy"), *host.Identity)
+
+	// the fields of the host take precedence over the ssh config.
+	user := "admin"
+	host, err = applySSHConfig("", types.Host{Host: "web", User: &user}, e)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", *host.User)
+	assert.Equal(t, 2222, *host.Port)
+}
+
+func TestApplySSHConfigByAlias(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "keys"), 0700)
+	os.WriteFile(filepath.Join(dir, "keys", "id_web1"), []byte("key"), 0600)
+	e := writeSSHConfig(t, dir, `
+Host web1
+  User deploy
+  IdentityFile ~/keys/id_web1
+  ProxyJump bastion
+
+Host 10.0.0.1
+  User root
+  Port 2222
+`)
+
+	// the block of the alias wins over the block of the host name.
+	host, err := applySSHConfig("web1", types.Host{Host: "10.0.0.1"}, e)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", host.Host)
+	assert.Equal(t, "deploy", *host.User)
+	assert.Equal(t, 2222, *host.Port)
+	assert.Equal(t, "bastion", *host.ProxyJump)
+	assert.Equal(t, filepath.Join(dir, "keys", "id_web1"), *host.Identity)
+
+	inventory := types.Hosts{
+		"web1":    types.Host{Host: "10.0.0.1"},
+		"bastion": types.Host{Host: "10.0.0.9"},
+	}
+	route, err := sshRoute("web1", inventory["web1"], e, inventory)
+	assert.NoError(t, err)
+	assert.Len(t, route, 2)
+	assert.Equal(t, "10.0.0.9", route[0].Host)
+	assert.Equal(t, "10.0.0.1", route[1].Host)
+}
+
+func TestSSHRoute(t *testing.T) {
+	e := types.NewEnv()
+	e.Set("XTASK_SSH_CONFIG", "none")
+
+	jump := "bastion,ops@10.0.0.2:2022"
+	inventory := types.Hosts{"bastion": types.Host{Host: "bastion.example.com"}}
+	route, err := sshRoute("", types.Host{Host: "10.0.1.5", ProxyJump: &jump}, e, inventory)
+	assert.NoError(t, err)
+
+	hosts := []string{}
+	for _, host := range route {
+		hosts = append(hosts, host.Host)
+	}
+	assert.Equal(t, []string{"bastion.example.com", "10.0.0.2", "10.0.1.5"}, hosts)
+	assert.Equal(t, "ops", *route[1].User)
+	assert.Equal(t, 2022, *route[1].Port)
+
+	loop := "b"
+	loop2 := "a"
+	inventory = types.Hosts{
+		"a": types.Host{Host: "a", ProxyJump: &loop},
+		"b": types.Host{Host: "b", ProxyJump: &loop2},
+	}
+	_, err = sshRoute("a", inventory["a"], e, inventory)
+	assert.Error(t, err)
+}
+
+func TestSSHThroughProxyJump(t *testing.T) {
+	dir := t.TempDir()
+	key, pub := newTestKey(t, dir)
+	bastion := newTestSSHServer(t, pub)
+	app := newTestSSHServer(t, pub)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	e := writeSSHConfig(t, dir, `
+Host bastion
+  HostName 127.0.0.1
+  Port `+strconv.Itoa(bastion.port)+`
+  User jump
+
+Host app-*
+  HostName 127.0.0.1
+  Port `+strconv.Itoa(app.port)+`
+  User deploy
+  ProxyJump bastion
+
+Host *
+  IdentityFile `+key+`
+`)
+	e.Set("XTASK_SSH_HOST_KEY_CHECK", HostKeyCheckAcceptNew)
+	e.Set("XTASK_SSH_KNOWN_HOSTS", filepath.Join(t.TempDir(), "known_hosts"))
+
+	client, err := newSSHClient("", types.Host{Host: "app-1"}, e, nil)
+	assert.NoError(t, err)
+	out, err := client.Run("echo hello")
+	client.Close()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(out))
+	assert.Equal(t, []string{"jump"}, bastion.users)
+	assert.Equal(t, []string{app.addr}, bastion.forwards)
+	assert.Equal(t, []string{"deploy"}, app.users)
+
+	ctx := TaskContext{Context: context.Background()}
+	ctx.Task.Id = "remote"
+	ctx.Data.Env = *e
+	ctx.Data.Uses = "ssh://app-2"
+	ctx.Data.Run = "test \"$(whoami)\" != ''"
+	res := runSSH(ctx)
+	assert.Equal(t, statuses.Ok, res.Status)
+}
+
+func TestSSHProxyJumpInventoryAlias(t *testing.T) {
+	bastion := newTestSSHServer(t, nil)
+	app := newTestSSHServer(t, nil)
+
+	e := types.NewEnv()
+	e.Set("XTASK_SSH_CONFIG", "none")
+	e.Set("XTASK_SSH_HOST_KEY_CHECK", HostKeyCheckAcceptNew)
+	e.Set("XTASK_SSH_KNOWN_HOSTS", filepath.Join(t.TempDir(), "known_hosts"))
+	e.Set("SSH_PASSWORD", "secret")
+
+	password := "SSH_PASSWORD"
+	user := "ops"
+	jump := "bastion"
+	inventory := types.Hosts{
+		"bastion": types.Host{Host: "127.0.0.1", Port: &bastion.port, User: &user, Password: &password},
+	}
+
+	client, err := newSSHClient("", types.Host{Host: "127.0.0.1", Port: &app.port, User: &user, Password: &password, ProxyJump: &jump}, e, inventory)
+	assert.NoError(t, err)
+	out, err := client.Run("echo $((1 + 2))")
+	client.Close()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "3", strings.TrimSpace(string(out)))
+	assert.Equal(t, []string{app.addr}, bastion.forwards)
+}
+
+func TestParseJumpHost(t *testing.T) {
+	host, err := parseJumpHost("ssh://admin@[::1]:2200", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "::1", host.Host)
+	assert.Equal(t, "admin", *host.User)
+	assert.Equal(t, 2200, *host.Port)
+
+	host, err = parseJumpHost("bastion.example.com", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "bastion.example.com", host.Host)
+	assert.Nil(t, host.Port)
+}
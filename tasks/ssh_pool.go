@@ -0,0 +1,166 @@
+package tasks
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyprxlabs/xtask/errors"
+	"github.com/hyprxlabs/xtask/types"
+	goph "github.com/melbahja/goph"
+)
+
+// SSHPool keeps ssh connections open so that the tasks of a run that
+// connect to the same host reuse one authenticated connection. Sessions
+// are multiplexed over the connection, so tasks may use it concurrently.
+type SSHPool struct {
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+	closed  bool
+}
+
+type pooledClient struct {
+	ready  chan struct{}
+	client *goph.Client
+	err    error
+}
+
+// NewSSHPool returns an empty pool. Close must be called to close the
+// connections of the pool.
+func NewSSHPool() *SSHPool {
+	return &SSHPool{clients: map[string]*pooledClient{}}
+}
+
+// Close closes all of the connections of the pool.
+func (p *SSHPool) Close() error {
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = map[string]*pooledClient{}
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, entry := range clients {
+		<-entry.ready
+		if entry.client != nil {
+			entry.client.Close()
+		}
+	}
+
+	return nil
+}
+
+// get returns the connection for target, connecting when the pool has no
+// open connection for the same route, user and authentication.
+func (p *SSHPool) get(alias string, target types.Host, e *types.Env, inventory types.Hosts) (*goph.Client, error) {
+	route, err := sshRoute(alias, target, e, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	key := sshPoolKey(route, e)
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("The SSH connection pool is closed")
+	}
+
+	entry, ok := p.clients[key]
+	if !ok {
+		entry = &pooledClient{ready: make(chan struct{})}
+		p.clients[key] = entry
+	}
+	p.mu.Unlock()
+
+	// the tasks waiting for the connection get its error instead of
+	// connecting again, so a wrong key or password is only tried once.
+	if ok {
+		<-entry.ready
+		return entry.client, entry.err
+	}
+
+	entry.client, entry.err = newSSHClient(alias, target, e, inventory)
+	if entry.err != nil {
+		p.remove(key, entry)
+		close(entry.ready)
+		return nil, entry.err
+	}
+
+	close(entry.ready)
+
+	// remove the connection once it is closed by the remote host.
+	go func() {
+		entry.client.Wait()
+		p.remove(key, entry)
+	}()
+
+	return entry.client, nil
+}
+
+func (p *SSHPool) remove(key string, entry *pooledClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clients[key] == entry {
+		delete(p.clients, key)
+	}
+}
+
+// sshPoolKey identifies the connection to a host by the hosts of the
+// route and the user, port, authentication and host key settings of each.
+func sshPoolKey(route []types.Host, e *types.Env) string {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	parts := []string{}
+	for _, host := range route {
+		port := 22
+		if host.Port != nil && *host.Port > 0 {
+			port = *host.Port
+		}
+
+		// the password is the name of the env var, so the value is part of
+		// the key in case the var is changed by a task.
+		password := value(host.Password)
+		if p, ok := e.Get(password); ok && password != "" {
+			password += "=" + p
+		}
+
+		parts = append(parts, strings.Join([]string{
+			value(host.User),
+			host.Host,
+			strconv.Itoa(port),
+			value(host.Identity),
+			password,
+			value(host.HostKeyCheck),
+			value(host.KnownHosts),
+		}, "\x00"))
+	}
+
+	return strings.Join(parts, "\x01")
+}
+
+// connectSSH returns a connection to target from the pool of the task, or
+// a new connection when the task has no pool. release must be called once
+// the connection is no longer used.
+func connectSSH(ctx TaskContext, alias string, target types.Host) (*goph.Client, func(), error) {
+	if ctx.SSHPool == nil {
+		client, err := newSSHClient(alias, target, &ctx.Data.Env, ctx.Inventory)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return client, func() { client.Close() }, nil
+	}
+
+	client, err := ctx.SSHPool.get(alias, target, &ctx.Data.Env, ctx.Inventory)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, func() {}, nil
+}
@@ -0,0 +1,134 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPoolContext(server *testSSHServer, pool *SSHPool) TaskContext {
+	password := "SSH_PASSWORD"
+	user := "deploy"
+	insecure := HostKeyCheckInsecure
+
+	ctx := TaskContext{Context: context.Background(), SSHPool: pool}
+	ctx.Task.Id = "remote"
+	ctx.Data.Uses = "ssh"
+	ctx.Data.Env = *types.NewEnv()
+	ctx.Data.Env.Set("XTASK_SSH_CONFIG", "none")
+	ctx.Data.Env.Set("SSH_PASSWORD", "secret")
+	ctx.Data.Hosts = types.Hosts{
+		"app": types.Host{Host: "127.0.0.1", Port: &server.port, User: &user, Password: &password, HostKeyCheck: &insecure},
+	}
+	return ctx
+}
+
+func (s *testSSHServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.users)
+}
+
+func TestSSHPoolReusesConnections(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	pool := NewSSHPool()
+
+	ctx := newPoolContext(server, pool)
+	ctx.Data.Run = "true"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := runSSH(ctx)
+			assert.Equal(t, statuses.Ok, res.Status)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, server.connections())
+
+	// another user is another connection.
+	other := "admin"
+	host := ctx.Data.Hosts["app"]
+	host.User = &other
+	ctx.Data.Hosts = types.Hosts{"app": host}
+	res := runSSH(ctx)
+	assert.Equal(t, statuses.Ok, res.Status)
+	assert.Equal(t, 2, server.connections())
+
+	assert.NoError(t, pool.Close())
+	_, err := pool.get("", host, &ctx.Data.Env, nil)
+	assert.Error(t, err)
+}
+
+func TestSSHPoolDialsOnceWhenAuthFails(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	server.authDelay = 200 * time.Millisecond
+	pool := NewSSHPool()
+	defer pool.Close()
+
+	ctx := newPoolContext(server, pool)
+	ctx.Data.Env.Set("SSH_PASSWORD", "wrong")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = pool.get("app", ctx.Data.Hosts["app"], &ctx.Data.Env, nil)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.Error(t, err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 1, server.dials)
+}
+
+func TestSSHPoolReconnectsClosedConnections(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	pool := NewSSHPool()
+	defer pool.Close()
+
+	ctx := newPoolContext(server, pool)
+	host := ctx.Data.Hosts["app"]
+
+	client, err := pool.get("", host, &ctx.Data.Env, nil)
+	assert.NoError(t, err)
+	client.Close()
+	client.Wait()
+
+	// the closed connection is removed by the pool in the background.
+	assert.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.clients) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	ctx.Data.Run = "true"
+	res := runSSH(ctx)
+	assert.Equal(t, statuses.Ok, res.Status)
+	assert.Equal(t, 2, server.connections())
+}
+
+func TestConnectSSHWithoutPool(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Run = "true"
+
+	assert.Equal(t, statuses.Ok, runSSH(ctx).Status)
+	assert.Equal(t, statuses.Ok, runSSH(ctx).Status)
+	assert.Equal(t, 2, server.connections())
+}
@@ -0,0 +1,112 @@
+package tasks
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/errors"
+	goph "github.com/melbahja/goph"
+)
+
+// remoteShell is how a script file is run by an interpreter on a remote
+// host.
+type remoteShell struct {
+	ext  string
+	args []string
+}
+
+// remoteShells mirrors the shells used to run scripts locally.
+var remoteShells = map[string]remoteShell{
+	"bash":    {ext: ".sh", args: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail"}},
+	"sh":      {ext: ".sh", args: []string{"sh", "-e"}},
+	"zsh":     {ext: ".sh", args: []string{"zsh", "-e"}},
+	"pwsh":    {ext: ".ps1", args: []string{"pwsh", "-NoProfile", "-NoLogo", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}},
+	"python":  {ext: ".py", args: []string{"python"}},
+	"python3": {ext: ".py", args: []string{"python3"}},
+	"node":    {ext: ".js", args: []string{"node"}},
+	"deno":    {ext: ".ts", args: []string{"deno", "run", "-A"}},
+	"bun":     {ext: ".ts", args: []string{"bun", "run"}},
+	"ruby":    {ext: ".rb", args: []string{"ruby"}},
+	"perl":    {ext: ".pl", args: []string{"perl"}},
+}
+
+// uploadScript writes script to a private temp dir on the remote host over
+// sftp and returns the command that runs the file with shell and args.
+// Other shells are used as the path of the interpreter. When the script
+// runs as another user than root, the user is granted access to the dir
+// and the file with setfacl. cleanup removes the dir.
+func uploadScript(client *goph.Client, ctx TaskContext, shell string, script string, b *become) (string, func(), error) {
+	interpreter, ok := remoteShells[shell]
+	if !ok {
+		interpreter = remoteShell{args: []string{shell}}
+	}
+
+	tempDir := withString(ctx.Data.With, "temp-dir")
+	if tempDir == "" {
+		tempDir = "/tmp"
+	}
+
+	// mktemp creates the dir with mode 0700, so the script is never
+	// readable by other users, e.g. while it is uploaded.
+	name := "xtask-" + sanitizeName(ctx.Data.Id, "-") + "-XXXXXXXX"
+	out, err := client.Run("mktemp -d " + shellQuote(path.Join(tempDir, name)))
+	if err != nil {
+		return "", nil, errors.New("Failed to create a temp dir in " + tempDir + ": " + strings.TrimSpace(string(out)) + " " + err.Error())
+	}
+
+	dir := strings.TrimSpace(string(out))
+	file := path.Join(dir, "script"+interpreter.ext)
+	cleanup := func() {
+		client.Run("rm -rf " + shellQuote(dir))
+	}
+
+	fail := func(msg string, err error) (string, func(), error) {
+		cleanup()
+		return "", nil, errors.New(msg + ": " + err.Error())
+	}
+
+	ftp, err := client.NewSftp()
+	if err != nil {
+		return fail("Failed to start sftp to upload the script", err)
+	}
+	defer ftp.Close()
+
+	f, err := ftp.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return fail("Failed to create the script "+file, err)
+	}
+
+	if !strings.HasSuffix(script, "\n") {
+		script += "\n"
+	}
+
+	_, err = f.Write([]byte(script))
+	f.Close()
+	if err == nil {
+		err = ftp.Chmod(file, 0700)
+	}
+
+	if err != nil {
+		return fail("Failed to write the script "+file, err)
+	}
+
+	if b != nil && b.user != "root" {
+		user := shellQuote("u:" + b.user)
+		out, err := client.Run("setfacl -m " + user + ":x " + shellQuote(dir) + " && setfacl -m " + user + ":rx " + shellQuote(file))
+		if err != nil {
+			return fail("Failed to grant "+b.user+" access to the script with setfacl: "+strings.TrimSpace(string(out)), err)
+		}
+	}
+
+	args := append([]string{}, interpreter.args...)
+	args = append(args, file)
+	args = append(args, ctx.Args...)
+
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	return strings.Join(quoted, " "), cleanup, nil
+}
@@ -0,0 +1,70 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSHScriptWithShell(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	tmp := t.TempDir()
+
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Id = "deploy"
+	ctx.Args = []string{"a b", "c"}
+	ctx.Data.With = map[string]interface{}{"shell": "bash", "temp-dir": tmp}
+	ctx.Data.Run = "echo \"$1|$2|${0##*.}\""
+
+	stdout := &bytes.Buffer{}
+	code, err := runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "a b|c|sh\n", stdout.String())
+
+	// the script is uploaded to a dir that only the user can read.
+	stdout.Reset()
+	ctx.Data.Run = "ls -ld \"$(dirname \"$0\")\" | cut -c1-10"
+	code, err = runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "drwx------\n", stdout.String())
+
+	// bash runs with pipefail, as it does locally.
+	ctx.Data.Run = "false | true"
+	code, err = runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, &bytes.Buffer{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, code)
+
+	// the script is removed after it runs, even when it fails.
+	ctx.Data.Run = "exit 3"
+	code, err = runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, &bytes.Buffer{})
+	assert.Error(t, err)
+	assert.Equal(t, 3, code)
+
+	entries, _ := os.ReadDir(tmp)
+	assert.Empty(t, entries)
+}
+
+func TestSSHScriptWithPython(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+
+	server := newTestSSHServer(t, nil)
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Id = "report"
+	ctx.Args = []string{"world"}
+	ctx.Data.With = map[string]interface{}{"shell": "python3", "temp-dir": t.TempDir()}
+	ctx.Data.Run = "import sys\nprint('hello ' + sys.argv[1])"
+
+	stdout := &bytes.Buffer{}
+	code, err := runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hello world\n", stdout.String())
+}
@@ -0,0 +1,330 @@
+package tasks
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is an ssh server for tests that runs commands with the
+// local shell, serves sftp and forwards direct-tcpip and tcpip-forward
+// connections.
+type testSSHServer struct {
+	addr     string
+	port     int
+	mu       sync.Mutex
+	users    []string
+	forwards []string
+	dials    int
+	// authDelay delays the rejection of a wrong password.
+	authDelay time.Duration
+}
+
+// newTestKey writes a new private key to dir and returns the file and the
+// public key.
+func newTestKey(t *testing.T, dir string) (string, ssh.PublicKey) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "id_test")
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file, signer.PublicKey()
+}
+
+// newTestSSHServer starts a server that accepts the authorized key or the
+// password "secret".
+func newTestSSHServer(t *testing.T, authorized ssh.PublicKey) *testSSHServer {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test ssh server runs commands with sh")
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testSSHServer{}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "secret" {
+				return nil, nil
+			}
+			time.Sleep(server.authDelay)
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server.addr = listener.Addr().String()
+	server.port = listener.Addr().(*net.TCPAddr).Port
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.mu.Lock()
+			server.dials++
+			server.mu.Unlock()
+			go server.serve(conn, config)
+		}
+	}()
+
+	return server
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+
+	s.mu.Lock()
+	s.users = append(s.users, sconn.User())
+	s.mu.Unlock()
+
+	go s.requests(sconn, reqs)
+	for newChan := range chans {
+		switch newChan.ChannelType() {
+		case "session":
+			go s.session(newChan)
+		case "direct-tcpip":
+			go s.forward(newChan)
+		default:
+			newChan.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// requests handles tcpip-forward by listening on the local address and
+// forwarding the connections back to the client.
+func (s *testSSHServer) requests(conn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	listeners := map[uint32]net.Listener{}
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	for req := range reqs {
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+
+		switch req.Type {
+		case "tcpip-forward":
+			ssh.Unmarshal(req.Payload, &payload)
+			l, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+
+			port := uint32(l.Addr().(*net.TCPAddr).Port)
+			listeners[port] = l
+			req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+			go s.forwardBack(conn, l, payload.Addr, port)
+		case "cancel-tcpip-forward":
+			ssh.Unmarshal(req.Payload, &payload)
+			if l, ok := listeners[payload.Port]; ok {
+				l.Close()
+				delete(listeners, payload.Port)
+			}
+			req.Reply(true, nil)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+func (s *testSSHServer) forwardBack(conn *ssh.ServerConn, l net.Listener, addr string, port uint32) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			payload := ssh.Marshal(struct {
+				Addr     string
+				Port     uint32
+				OrigAddr string
+				OrigPort uint32
+			}{addr, port, "127.0.0.1", uint32(c.RemoteAddr().(*net.TCPAddr).Port)})
+
+			ch, reqs, err := conn.OpenChannel("forwarded-tcpip", payload)
+			if err != nil {
+				c.Close()
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+
+			go func() {
+				io.Copy(ch, c)
+				ch.CloseWrite()
+			}()
+
+			io.Copy(c, ch)
+			c.Close()
+		}()
+	}
+}
+
+func (s *testSSHServer) forward(newChan ssh.NewChannel) {
+	var payload struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+
+	if err := ssh.Unmarshal(newChan.ExtraData(), &payload); err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	addr := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.forwards = append(s.forwards, addr)
+	s.mu.Unlock()
+
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		io.Copy(ch, target)
+		ch.Close()
+	}()
+
+	io.Copy(target, ch)
+	target.Close()
+}
+
+func (s *testSSHServer) session(newChan ssh.NewChannel) {
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+
+	env := os.Environ()
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var payload struct{ Name, Value string }
+			ssh.Unmarshal(req.Payload, &payload)
+			env = append(env, payload.Name+"="+payload.Value)
+			req.Reply(true, nil)
+		case "pty-req":
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			ssh.Unmarshal(req.Payload, &payload)
+			req.Reply(true, nil)
+
+			cmd := exec.Command("sh", "-c", payload.Command)
+			cmd.Env = env
+			cmd.Stdout = ch
+			cmd.Stderr = ch.Stderr()
+
+			// like sshd, the command does not wait for the client to
+			// close stdin.
+			stdin, _ := cmd.StdinPipe()
+			go func() {
+				io.Copy(stdin, ch)
+				stdin.Close()
+			}()
+
+			code := 0
+			if err := cmd.Run(); err != nil {
+				code = 1
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					code = exitErr.ExitCode()
+				}
+			}
+
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(code)}))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			ssh.Unmarshal(req.Payload, &payload)
+			if payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+
+			req.Reply(true, nil)
+			server, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+
+			server.Serve()
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
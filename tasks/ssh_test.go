@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSHForwardsParamsAndInputs(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Env.Set("PARAM_TARGET", "eu-west")
+	ctx.Data.Env.Set("INPUT_PROJECT", "app.csproj")
+	ctx.Data.Env.Set("XTASK_TEST_UNSET", "leaked")
+	ctx.Data.Run = "echo \"$PARAM_TARGET|$INPUT_PROJECT|${XTASK_TEST_UNSET:-}\""
+
+	stdout := &bytes.Buffer{}
+	code, err := runSSHTarget(context.Background(), ctx, "app", ctx.Data.Hosts["app"], stdout, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "eu-west|app.csproj|\n", stdout.String())
+}
@@ -0,0 +1,276 @@
+package tasks
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyprxlabs/xtask/errors"
+)
+
+// forward is a port forward of an ssh-tunnel task, written like the -L and
+// -R options of ssh: [bind_address:]port:host:hostport.
+type forward struct {
+	bind     string
+	port     string
+	host     string
+	hostPort string
+}
+
+func (f forward) listenAddr() string { return net.JoinHostPort(f.bind, f.port) }
+func (f forward) targetAddr() string { return net.JoinHostPort(f.host, f.hostPort) }
+
+// parseForward parses a forward spec. IPv6 addresses are written in
+// brackets, e.g. [::1]:5432:db:5432.
+func parseForward(spec string) (forward, error) {
+	parts := []string{}
+	start := 0
+	depth := 0
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, spec[start:])
+
+	for i := range parts {
+		parts[i] = strings.TrimSuffix(strings.TrimPrefix(parts[i], "["), "]")
+	}
+
+	f := forward{}
+	switch len(parts) {
+	case 3:
+		f.port, f.host, f.hostPort = parts[0], parts[1], parts[2]
+	case 4:
+		f.bind, f.port, f.host, f.hostPort = parts[0], parts[1], parts[2], parts[3]
+	default:
+		return f, errors.New("Invalid forward " + spec + ", expected [bind_address:]port:host:hostport")
+	}
+
+	for _, port := range []string{f.port, f.hostPort} {
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			return f, errors.New("Invalid port " + port + " in forward " + spec)
+		}
+	}
+
+	if f.host == "" {
+		return f, errors.New("Missing host in forward " + spec)
+	}
+
+	if f.bind == "" {
+		f.bind = "127.0.0.1"
+	}
+
+	return f, nil
+}
+
+// tunnel holds the listeners of an ssh-tunnel task open until it is
+// closed by the workflow.
+type tunnel struct {
+	mu        sync.Mutex
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+	release   func()
+	once      sync.Once
+}
+
+// serve accepts connections on l and copies them to the connections
+// returned by dial until the tunnel is closed.
+func (t *tunnel) serve(l net.Listener, dial func() (net.Conn, error), stderr io.Writer) {
+	t.mu.Lock()
+	t.listeners = append(t.listeners, l)
+	t.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				target, err := dial()
+				if err != nil {
+					io.WriteString(stderr, "Failed to forward "+l.Addr().String()+": "+err.Error()+"\n")
+					conn.Close()
+					return
+				}
+
+				if !t.track(conn, target) {
+					return
+				}
+
+				go func() {
+					io.Copy(target, conn)
+					target.Close()
+				}()
+
+				io.Copy(conn, target)
+				conn.Close()
+				t.untrack(conn, target)
+			}()
+		}
+	}()
+}
+
+func (t *tunnel) track(conns ...net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// the tunnel was closed while dialing.
+	if t.conns == nil {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+
+	for _, conn := range conns {
+		t.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (t *tunnel) untrack(conns ...net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, conn := range conns {
+		delete(t.conns, conn)
+	}
+}
+
+// Close stops the listeners, closes the forwarded connections and
+// releases the ssh connection.
+func (t *tunnel) Close() error {
+	t.once.Do(func() {
+		t.mu.Lock()
+		for _, l := range t.listeners {
+			l.Close()
+		}
+
+		for conn := range t.conns {
+			conn.Close()
+		}
+		t.conns = nil
+		t.mu.Unlock()
+
+		t.release()
+	})
+
+	return nil
+}
+
+// runSSHTunnel opens the forwards of with.forward (ssh -L) and
+// with.remote-forward (ssh -R) through a host. The forwards stay open
+// after the task completes and are closed by the workflow once the tasks
+// that need the task have completed.
+func runSSHTunnel(ctx TaskContext) *TaskResult {
+	res := NewTaskResult()
+	uses := ctx.Data.Uses
+	if uses == "ssh-tunnel" {
+		uses = "ssh-tunnel://"
+	}
+
+	uri, err := url.Parse(uses)
+	if err != nil {
+		return res.Fail(errors.New("Invalid SSH URI: " + err.Error()))
+	}
+
+	if uri.Scheme != "ssh-tunnel" {
+		return res.Fail(errors.New("Invalid SSH URI scheme: " + uri.Scheme))
+	}
+
+	targets := []hostTarget{}
+	if uri.Host != "" {
+		target, err := uriHostTarget(uri, ctx.Inventory)
+		if err != nil {
+			return res.Fail(err)
+		}
+
+		targets = append(targets, target)
+	} else if len(ctx.Data.Hosts) > 0 {
+		targets = hostTargets(ctx.Data.Hosts)
+	}
+
+	if len(targets) != 1 {
+		return res.Fail(errors.New("An ssh-tunnel task needs exactly one host, found " + strconv.Itoa(len(targets))))
+	}
+
+	locals := []forward{}
+	for _, spec := range withStrings(ctx.Data.With, "forward") {
+		f, err := parseForward(spec)
+		if err != nil {
+			return res.Fail(err)
+		}
+		locals = append(locals, f)
+	}
+
+	remotes := []forward{}
+	for _, spec := range withStrings(ctx.Data.With, "remote-forward") {
+		f, err := parseForward(spec)
+		if err != nil {
+			return res.Fail(err)
+		}
+		remotes = append(remotes, f)
+	}
+
+	if len(locals) == 0 && len(remotes) == 0 {
+		return res.Fail(errors.New("No forward or remote-forward specified for ssh-tunnel task"))
+	}
+
+	target := targets[0]
+	client, release, err := connectSSH(ctx, target.alias, target.host)
+	if err != nil {
+		return res.Fail(err)
+	}
+
+	t := &tunnel{conns: map[net.Conn]struct{}{}, release: release}
+	for i, f := range locals {
+		l, err := net.Listen("tcp", f.listenAddr())
+		if err != nil {
+			t.Close()
+			return res.Fail(errors.New("Failed to listen on " + f.listenAddr() + ": " + err.Error()))
+		}
+
+		addr := f.targetAddr()
+		t.serve(l, func() (net.Conn, error) { return client.Dial("tcp", addr) }, os.Stderr)
+		os.Stdout.WriteString("Forwarding " + l.Addr().String() + " to " + addr + " via " + target.alias + "\n")
+
+		// the port is known here when the forward listens on port 0.
+		if i == 0 {
+			res.Output["port"] = strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+		}
+	}
+
+	for i, f := range remotes {
+		l, err := client.Listen("tcp", f.listenAddr())
+		if err != nil {
+			t.Close()
+			return res.Fail(errors.New("Failed to listen on " + f.listenAddr() + " on " + target.alias + ": " + err.Error()))
+		}
+
+		addr := f.targetAddr()
+		t.serve(l, func() (net.Conn, error) { return net.Dial("tcp", addr) }, os.Stderr)
+		os.Stdout.WriteString("Forwarding " + l.Addr().String() + " on " + target.alias + " to " + addr + "\n")
+
+		if i == 0 {
+			res.Output["remote-port"] = strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+		}
+	}
+
+	res.Closer = t
+	return res.Ok()
+}
@@ -0,0 +1,112 @@
+package tasks
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseForward(t *testing.T) {
+	f, err := parseForward("5432:db.internal:5432")
+	assert.NoError(t, err)
+	assert.Equal(t, forward{bind: "127.0.0.1", port: "5432", host: "db.internal", hostPort: "5432"}, f)
+
+	f, err = parseForward("0.0.0.0:8080:localhost:80")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8080", f.listenAddr())
+	assert.Equal(t, "localhost:80", f.targetAddr())
+
+	f, err = parseForward("[::1]:6379:[fd00::5]:6379")
+	assert.NoError(t, err)
+	assert.Equal(t, "[::1]:6379", f.listenAddr())
+	assert.Equal(t, "[fd00::5]:6379", f.targetAddr())
+
+	_, err = parseForward("5432:db.internal")
+	assert.Error(t, err)
+
+	_, err = parseForward("pg:db.internal:5432")
+	assert.Error(t, err)
+}
+
+// newEchoServer returns the address of a server that writes back each line.
+func newEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					conn.Write([]byte(scanner.Text() + "\n"))
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func echo(t *testing.T, addr string) string {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("ping\n"))
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	return line
+}
+
+func TestSSHTunnelForwards(t *testing.T) {
+	server := newTestSSHServer(t, nil)
+	echoAddr := newEchoServer(t)
+
+	ctx := newPoolContext(server, nil)
+	ctx.Data.Uses = "ssh-tunnel"
+	ctx.Data.With = map[string]interface{}{
+		"forward":        []interface{}{"0:" + echoAddr},
+		"remote-forward": []interface{}{"0:" + echoAddr},
+	}
+
+	res := runSSHTunnel(ctx)
+	assert.NoError(t, res.Err)
+	assert.Equal(t, statuses.Ok, res.Status)
+	assert.NotNil(t, res.Closer)
+
+	local := "127.0.0.1:" + res.Output["port"].(string)
+	assert.Equal(t, "ping\n", echo(t, local))
+	server.mu.Lock()
+	assert.Contains(t, server.forwards, echoAddr)
+	server.mu.Unlock()
+
+	remote := "127.0.0.1:" + res.Output["remote-port"].(string)
+	assert.Equal(t, "ping\n", echo(t, remote))
+
+	res.Closer.Close()
+	_, err := net.Dial("tcp", local)
+	assert.Error(t, err)
+}
+
+func TestSSHTunnelNeedsOneHost(t *testing.T) {
+	ctx := TaskContext{}
+	ctx.Data.Uses = "ssh-tunnel"
+	ctx.Data.With = map[string]interface{}{"forward": "5432:db:5432"}
+
+	res := runSSHTunnel(ctx)
+	assert.Error(t, res.Err)
+	assert.Nil(t, res.Closer)
+}
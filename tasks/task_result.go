@@ -1,6 +1,7 @@
 package tasks
 
 import (
+	"io"
 	"time"
 
 	"github.com/hyprxlabs/xtask/errors"
@@ -14,6 +15,11 @@ type TaskResult struct {
 	EndedAt   time.Time
 	Message   string
 	Output    map[string]interface{}
+	// Attempts is the number of times the task was run, including retries.
+	Attempts int
+	// Closer stops what the task left running, e.g. the forwards of an
+	// ssh-tunnel task, once the tasks that need the task have completed.
+	Closer io.Closer
 }
 
 func (tr *TaskResult) Start() *TaskResult {
@@ -27,4 +27,10 @@ type TaskContext struct {
 	Context     context.Context
 	Args        []string
 	ContextName string
+	// Inventory holds all of the hosts of the xtaskfile so that hosts can
+	// refer to each other, e.g. as a proxy-jump.
+	Inventory types.Hosts
+	// SSHPool holds the ssh connections of the run. A new connection is
+	// used for each host when it is nil.
+	SSHPool *SSHPool
 }
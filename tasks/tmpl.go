@@ -102,6 +102,16 @@ func runTpl(ctx TaskContext) *TaskResult {
 		"arch": runtime.GOARCH,
 	}
 
+	// .host is set when the task has a single host.
+	hosts := map[string]interface{}{}
+	for alias, host := range ctx.Data.Hosts {
+		hosts[alias] = HostData(alias, host)
+		if len(ctx.Data.Hosts) == 1 {
+			data["host"] = hosts[alias]
+		}
+	}
+	data["hosts"] = hosts
+
 	for _, file := range files {
 		src := file
 		dest := file
@@ -0,0 +1,11 @@
+package tasks
+
+import "regexp"
+
+var nameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// sanitizeName replaces the runs of characters other than letters, digits,
+// _, . and - in s with replacement, so that s can be used in a file name.
+func sanitizeName(s string, replacement string) string {
+	return nameInvalid.ReplaceAllString(s, replacement)
+}
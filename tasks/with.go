@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// withString returns the value of key in with as a string. Numbers and
+// booleans are formatted.
+func withString(with map[string]interface{}, key string) string {
+	v, ok := with[key]
+	if !ok || v == nil {
+		return ""
+	}
+
+	switch value := v.(type) {
+	case string:
+		return value
+	case int, int64, float64, bool:
+		return fmt.Sprint(value)
+	}
+
+	return ""
+}
+
+// withStrings returns the value of key in with as a list of strings. A
+// single value is returned as a list with one item.
+func withStrings(with map[string]interface{}, key string) []string {
+	v, ok := with[key]
+	if !ok || v == nil {
+		return []string{}
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		if s := withString(with, key); s != "" {
+			return []string{s}
+		}
+
+		return []string{}
+	}
+
+	values := []string{}
+	for _, item := range items {
+		switch value := item.(type) {
+		case string:
+			values = append(values, value)
+		case int, int64, float64, bool:
+			values = append(values, fmt.Sprint(value))
+		}
+	}
+
+	return values
+}
+
+// withBool returns the value of key in with as a bool. The second value
+// is false when key is not set or is not a bool.
+func withBool(with map[string]interface{}, key string) (bool, bool) {
+	v, ok := with[key]
+	if !ok || v == nil {
+		return false, false
+	}
+
+	switch value := v.(type) {
+	case bool:
+		return value, true
+	case string:
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	}
+
+	return false, false
+}
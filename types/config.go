@@ -25,6 +25,23 @@ type Config struct {
 	Shell        string  `yaml:"shell,omitempty" mapstructure:"shell,omitempty"`
 	Substitution bool    `yaml:"substitution,omitempty" mapstructure:"substitution,omitempty"`
 	Context      *string `yaml:"context,omitempty" mapstructure:"context,omitempty"`
+	// SSH holds the defaults for ssh and scp tasks.
+	SSH SSHConfig `yaml:"ssh,omitempty" mapstructure:"ssh,omitempty"`
+}
+
+type SSHConfig struct {
+	// KnownHosts is the known_hosts file used to verify host keys. The
+	// default is ~/.ssh/known_hosts.
+	KnownHosts string `yaml:"known-hosts,omitempty" mapstructure:"known-hosts,omitempty"`
+	// HostKeyCheck is strict or accept-new. The default is strict and
+	// insecure may only be set per host.
+	HostKeyCheck string `yaml:"host-key-check,omitempty" mapstructure:"host-key-check,omitempty"`
+	// Config is the ssh config file used for host defaults. The default
+	// is ~/.ssh/config and none disables it.
+	Config string `yaml:"config,omitempty" mapstructure:"config,omitempty"`
+	// GatherFacts gathers the os of the hosts of every ssh and scp task
+	// instead of only for tasks that filter or test it.
+	GatherFacts bool `yaml:"gather-facts,omitempty" mapstructure:"gather-facts,omitempty"`
 }
 
 type Dirs struct {
@@ -32,6 +49,8 @@ type Dirs struct {
 	Apps    []string `yaml:"apps,omitempty" mapstructure:"apps,omitempty"`
 	Scripts string   `yaml:"scripts,omitempty" mapstructure:"scripts,omitempty"`
 	Bin     string   `yaml:"bin,omitempty" mapstructure:"bin,omitempty"`
+	// Tasks are the directories searched for shared tasks used by name.
+	Tasks []string `yaml:"tasks,omitempty" mapstructure:"tasks,omitempty"`
 }
 
 func (d *Dirs) UnmarshalYAML(node *yaml.Node) error {
@@ -118,6 +137,18 @@ func (d *Dirs) UnmarshalYAML(node *yaml.Node) error {
 				return errors.New("expected string value for dirs.bin")
 			}
 			d.Bin = valueNode.Value
+		case "tasks":
+			if valueNode.Kind == yaml.SequenceNode {
+				var dirs []string
+				if err := valueNode.Decode(&dirs); err != nil {
+					return err
+				}
+				d.Tasks = dirs
+			} else if valueNode.Kind == yaml.ScalarNode {
+				d.Tasks = []string{valueNode.Value}
+			} else {
+				return errors.New("expected string or sequence of strings for dirs.tasks")
+			}
 		default:
 			return errors.New("unknown key in dirs: " + key)
 		}
@@ -19,6 +19,21 @@ type Host struct {
 	Meta     map[string]interface{} `yaml:"meta,omitempty"`
 	OS       *OS                    `yaml:"os,omitempty"`
 	Defaults string                 `yaml:"defaults,omitempty"`
+	// HostKeyCheck is strict, accept-new or insecure. Strict is used
+	// when it is not set.
+	HostKeyCheck *string `yaml:"host-key-check,omitempty"`
+	// KnownHosts is the known_hosts file used to verify the host key.
+	KnownHosts *string `yaml:"known-hosts,omitempty"`
+	// ProxyJump is the host alias or user@host:port of the bastion used
+	// to reach the host. Several jumps are separated by commas and none
+	// disables the ProxyJump of ~/.ssh/config.
+	ProxyJump *string `yaml:"proxy-jump,omitempty"`
+	// Become runs the scripts of ssh tasks on the host as another user.
+	Become       *bool   `yaml:"become,omitempty"`
+	BecomeUser   *string `yaml:"become-user,omitempty"`
+	BecomeMethod *string `yaml:"become-method,omitempty"`
+	// BecomePassword must point to an env variable that contains the password.
+	BecomePassword *string `yaml:"become-password,omitempty"`
 }
 
 type HostsNode struct {
@@ -180,6 +195,42 @@ func (h *Host) UnmarshalYAML(value *yaml.Node) error {
 			if valNode.Kind == yaml.ScalarNode {
 				h.Password = &valNode.Value
 			}
+		case "defaults":
+			if valNode.Kind == yaml.ScalarNode {
+				h.Defaults = valNode.Value
+			}
+		case "host-key-check":
+			if valNode.Kind == yaml.ScalarNode {
+				h.HostKeyCheck = &valNode.Value
+			}
+		case "known-hosts":
+			if valNode.Kind == yaml.ScalarNode {
+				h.KnownHosts = &valNode.Value
+			}
+		case "proxy-jump":
+			if valNode.Kind == yaml.ScalarNode {
+				h.ProxyJump = &valNode.Value
+			}
+		case "become":
+			if valNode.Kind == yaml.ScalarNode {
+				become, err := strconv.ParseBool(valNode.Value)
+				if err != nil {
+					return errors.New("invalid become value for host: " + valNode.Value)
+				}
+				h.Become = &become
+			}
+		case "become-user":
+			if valNode.Kind == yaml.ScalarNode {
+				h.BecomeUser = &valNode.Value
+			}
+		case "become-method":
+			if valNode.Kind == yaml.ScalarNode {
+				h.BecomeMethod = &valNode.Value
+			}
+		case "become-password":
+			if valNode.Kind == yaml.ScalarNode {
+				h.BecomePassword = &valNode.Value
+			}
 		case "groups":
 			if valNode.Kind == yaml.SequenceNode {
 				groups := []string{}
@@ -223,92 +274,102 @@ func (h *Host) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// UnmarshalYAML reads hosts keyed by alias, or a list of hosts keyed by
+// their host name. A host may be written as user@host:port.
 func (hosts *Hosts) UnmarshalYAML(node *yaml.Node) error {
+	if *hosts == nil {
+		*hosts = Hosts{}
+	}
 
-	for _, item := range node.Content {
-		if item.Kind == yaml.ScalarNode {
-			next := &Host{}
-			hostname := item.Value
-			if strings.ContainsRune(item.Value, '@') {
-				parts := strings.SplitN(item.Value, "@", 2)
-				next.User = &parts[0]
-				hostname = parts[1]
-			}
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			switch item.Kind {
+			case yaml.ScalarNode:
+				host, err := parseHostString(item.Value)
+				if err != nil {
+					return err
+				}
+				(*hosts)[host.Host] = host
+			case yaml.MappingNode:
+				var host Host
+				if err := item.Decode(&host); err != nil {
+					return err
+				}
 
-			if strings.ContainsRune(hostname, ':') {
-				parts := strings.SplitN(hostname, ":", 2)
-				hostname = parts[0]
-				if len(parts[1]) > 0 {
-					port, err := strconv.Atoi(parts[1])
-					if err != nil {
-						return err
-					}
-					next.Port = &port
+				if host.Host == "" {
+					return errors.New("host entry missing host field")
 				}
+				(*hosts)[host.Host] = host
 			}
+		}
 
-			next.Host = hostname
-			(*hosts)[hostname] = *next
-			continue
+		return nil
+	}
 
-		}
+	if node.Kind != yaml.MappingNode {
+		return errors.New("invalid hosts entry")
+	}
 
-		if item.Kind != yaml.MappingNode {
-			continue
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valNode := node.Content[i+1]
+
+		if keyNode.Kind != yaml.ScalarNode {
+			return errors.New("host key must be a string")
 		}
 
-		for i := 0; i < len(item.Content); i += 2 {
-			keyNode := item.Content[i]
-			valNode := item.Content[i+1]
+		key := keyNode.Value
 
-			if keyNode.Kind != yaml.ScalarNode {
-				return errors.New("host key must be a string")
+		if valNode.Kind == yaml.ScalarNode {
+			host, err := parseHostString(valNode.Value)
+			if err != nil {
+				return err
 			}
+			(*hosts)[key] = host
+			continue
+		}
 
-			key := keyNode.Value
+		if valNode.Kind != yaml.MappingNode {
+			return errors.New("invalid host entry")
+		}
 
-			if valNode.Kind == yaml.ScalarNode {
-				next := &Host{}
-				hostname := valNode.Value
-				if strings.ContainsRune(valNode.Value, '@') {
-					parts := strings.SplitN(valNode.Value, "@", 2)
-					next.User = &parts[0]
-					hostname = parts[1]
-				}
+		var host Host
+		if err := valNode.Decode(&host); err != nil {
+			return err
+		}
 
-				if strings.ContainsRune(hostname, ':') {
-					parts := strings.SplitN(hostname, ":", 2)
-					hostname = parts[0]
-					if len(parts[1]) > 0 {
-						port, err := strconv.Atoi(parts[1])
-						if err != nil {
-							return err
-						}
-						next.Port = &port
-					}
-				}
+		if host.Host == "" {
+			return errors.New("host entry missing host field for " + key)
+		}
 
-				next.Host = hostname
-				(*hosts)[key] = *next
-				continue
-			}
+		(*hosts)[key] = host
+	}
 
-			if valNode.Kind != yaml.MappingNode {
-				return errors.New("invalid host entry")
-			}
+	return nil
+}
 
-			var host Host
-			if err := item.Decode(&host); err != nil {
-				return err
-			}
+// parseHostString parses user@host:port where user and port are optional.
+func parseHostString(value string) (Host, error) {
+	host := Host{}
+	hostname := value
+	if strings.ContainsRune(value, '@') {
+		parts := strings.SplitN(value, "@", 2)
+		host.User = &parts[0]
+		hostname = parts[1]
+	}
 
-			if host.Host == "" {
-				return errors.New("host entry missing host field for " + key)
+	if strings.ContainsRune(hostname, ':') {
+		parts := strings.SplitN(hostname, ":", 2)
+		hostname = parts[0]
+		if len(parts[1]) > 0 {
+			port, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return host, err
 			}
-
-			(*hosts)[key] = host
+			host.Port = &port
 		}
 	}
 
-	return nil
+	host.Host = hostname
+	return host, nil
 }
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matrix runs a task once for each combination of its values, e.g.
+// os: [linux, windows] and go: ["1.22", "1.23"]. Include adds values to
+// the combinations it matches, or a combination when it matches none,
+// and exclude removes the combinations it matches.
+type Matrix struct {
+	Values  map[string][]string `yaml:"-"`
+	Include []map[string]string `yaml:"include,omitempty"`
+	Exclude []map[string]string `yaml:"exclude,omitempty"`
+}
+
+func (m *Matrix) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return errors.New("matrix must be a mapping")
+	}
+
+	m.Values = map[string][]string{}
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+
+		switch keyNode.Value {
+		case "include":
+			if err := valueNode.Decode(&m.Include); err != nil {
+				return errors.New("invalid matrix include: " + err.Error())
+			}
+
+		case "exclude":
+			if err := valueNode.Decode(&m.Exclude); err != nil {
+				return errors.New("invalid matrix exclude: " + err.Error())
+			}
+
+		default:
+			switch valueNode.Kind {
+			case yaml.ScalarNode:
+				m.Values[keyNode.Value] = []string{valueNode.Value}
+			case yaml.SequenceNode:
+				values := []string{}
+				for _, item := range valueNode.Content {
+					if item.Kind != yaml.ScalarNode {
+						return errors.New("matrix values of " + keyNode.Value + " must be scalars")
+					}
+					values = append(values, item.Value)
+				}
+				m.Values[keyNode.Value] = values
+			default:
+				return errors.New("matrix values of " + keyNode.Value + " must be a list")
+			}
+		}
+	}
+
+	return nil
+}
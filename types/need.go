@@ -1,6 +1,57 @@
 package types
 
+import (
+	"errors"
+
+	"gopkg.in/yaml.v3"
+)
+
 type Need struct {
 	Name     string `yaml:"name"`
 	Parallel bool   `yaml:"async,omitempty"`
 }
+
+type Needs []Need
+
+func (n *Need) UnmarshalYAML(value *yaml.Node) error {
+
+	if value.Kind == yaml.ScalarNode {
+		n.Name = value.Value
+		return nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return errors.New("invalid need entry")
+	}
+
+	for i := 0; i < len(value.Content); i += 2 {
+		keyNode := value.Content[i]
+		valNode := value.Content[i+1]
+
+		switch keyNode.Value {
+		case "name":
+			if valNode.Kind == yaml.ScalarNode {
+				n.Name = valNode.Value
+			}
+		case "async", "parallel":
+			if valNode.Kind == yaml.ScalarNode {
+				n.Parallel = valNode.Value == "true"
+			}
+		}
+	}
+
+	if n.Name == "" {
+		return errors.New("need entry missing name field")
+	}
+
+	return nil
+}
+
+// Names returns the names of the needed tasks in the order they were declared.
+func (n Needs) Names() []string {
+	names := make([]string, 0, len(n))
+	for _, need := range n {
+		names = append(names, need.Name)
+	}
+	return names
+}
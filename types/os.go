@@ -1,11 +1,33 @@
 package types
 
+import "strings"
+
 type OS struct {
-	Platform     string `yaml:"platform"`
-	Arch         string `yaml:"arch"`
-	Variant      string `yaml:"variant,omitempty"`
-	Family       string `yaml:"family,omitempty"`
-	Codename     string `yaml:"codename,omitempty"`
-	Version      string `yaml:"version,omitempty"`
-	BuildVersion string `yaml:"build_version,omitempty"`
+	Platform string `yaml:"platform"`
+	Arch     string `yaml:"arch"`
+	Variant  string `yaml:"variant,omitempty"`
+	Family   string `yaml:"family,omitempty"`
+	// Like holds the distros the variant is like, the ID_LIKE of
+	// os-release, e.g. ubuntu and debian for linuxmint.
+	Like         []string `yaml:"like,omitempty"`
+	Codename     string   `yaml:"codename,omitempty"`
+	Version      string   `yaml:"version,omitempty"`
+	BuildVersion string   `yaml:"build_version,omitempty"`
+}
+
+// Matches reports whether name is the platform, family, variant, codename
+// or one of the distros the os is like, e.g. linux, debian, ubuntu or noble.
+func (o *OS) Matches(name string) bool {
+	if o == nil {
+		return false
+	}
+
+	values := append([]string{o.Platform, o.Family, o.Variant, o.Codename}, o.Like...)
+	for _, value := range values {
+		if value != "" && strings.EqualFold(value, name) {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Outputs []Output
+
+// UnmarshalYAML accepts a list of output names, a list of output
+// definitions or a mapping of output names to definitions:
+//
+//	outputs: [version]
+//	outputs:
+//	  - id: version
+//	    type: string
+//	outputs:
+//	  version:
+//	    desc: the version that was built
+func (o *Outputs) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			if item.Kind == yaml.ScalarNode {
+				*o = append(*o, Output{Id: item.Value})
+				continue
+			}
+
+			var output Output
+			if err := item.Decode(&output); err != nil {
+				return err
+			}
+
+			if output.Id == "" {
+				return errors.New("output entry missing id field")
+			}
+
+			*o = append(*o, output)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(value.Content); i += 2 {
+			keyNode := value.Content[i]
+			valNode := value.Content[i+1]
+
+			output := Output{}
+			if valNode.Kind == yaml.MappingNode {
+				if err := valNode.Decode(&output); err != nil {
+					return err
+				}
+			} else if valNode.Kind == yaml.ScalarNode && len(valNode.Value) > 0 {
+				desc := valNode.Value
+				output.Desc = &desc
+			}
+
+			output.Id = keyNode.Value
+			*o = append(*o, output)
+		}
+	default:
+		return errors.New("outputs must be a list or a mapping")
+	}
+
+	return nil
+}
+
+// Get returns the output with the given id.
+func (o Outputs) Get(id string) (Output, bool) {
+	for _, output := range o {
+		if output.Id == id {
+			return output, true
+		}
+	}
+
+	return Output{}, false
+}
@@ -18,10 +18,47 @@ type Task struct {
 	Run       *string                `yaml:"run,omitempty"`
 	Uses      *string                `yaml:"uses,omitempty"`
 	Args      []string               `yaml:"args,omitempty"`
-	Needs     []string               `yaml:"needs,omitempty"`
+	Needs     Needs                  `yaml:"needs,omitempty"`
 	Hosts     []string               `yaml:"hosts,omitempty"`
 	With      map[string]interface{} `yaml:"with,omitempty"`
 	Predicate *string                `yaml:"if,omitempty"`
+	Outputs   Outputs                `yaml:"outputs,omitempty"`
+	// Retries is the number of times a failed task is run again.
+	Retries int `yaml:"retries,omitempty"`
+	// RetryDelay is the delay before the first retry. The delay doubles
+	// for each retry after that.
+	RetryDelay *string `yaml:"retry-delay,omitempty"`
+	// ContinueOnError allows the run to continue when the task fails.
+	ContinueOnError bool `yaml:"continue-on-error,omitempty"`
+	// Sources are glob patterns, relative to cwd, of the files the task
+	// reads. The task is skipped when they did not change since the last
+	// successful run.
+	Sources []string `yaml:"sources,omitempty"`
+	// Generates are glob patterns of the files the task creates. The task
+	// is not skipped when any of them is missing.
+	Generates []string `yaml:"generates,omitempty"`
+	// Become runs the script of an ssh task as another user. The become
+	// fields of the task take precedence over the fields of the host.
+	Become *bool `yaml:"become,omitempty"`
+	// BecomeUser is the user the script runs as. The default is root.
+	BecomeUser *string `yaml:"become-user,omitempty"`
+	// BecomeMethod is sudo, su or doas. The default is sudo.
+	BecomeMethod *string `yaml:"become-method,omitempty"`
+	// BecomePassword is the name of the env var that holds the password.
+	BecomePassword *string `yaml:"become-password,omitempty"`
+	// OS limits the hosts of the task to those whose os matches one of
+	// the names, e.g. debian. The os of hosts that do not declare it is
+	// gathered over ssh.
+	OS []string `yaml:"os,omitempty"`
+	// Inputs are the values the task accepts in with. They are set by the
+	// shared task the task uses.
+	Inputs []Input `yaml:"inputs,omitempty"`
+	// Params are the command line flags of the task, parsed from the args
+	// after the task name, e.g. `xtask run deploy --tag v1.0.0`.
+	Params []Param `yaml:"params,omitempty"`
+	// Matrix runs the task once for each combination of its values. The
+	// task itself then only needs the combinations.
+	Matrix *Matrix `yaml:"matrix,omitempty"`
 }
 
 type Tasks map[string]Task
@@ -64,7 +101,7 @@ func (t *Tasks) UnmarshalYAML(node *yaml.Node) error {
 				With:      map[string]interface{}{},
 				Dotenv:    []string{},
 				Args:      []string{},
-				Needs:     []string{},
+				Needs:     Needs{},
 				Hosts:     []string{},
 				Predicate: nil,
 			}
@@ -77,6 +114,8 @@ func (t *Tasks) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// SharedTask is a task defined in its own file that other tasks use with
+// `uses: ./path/name.xtask.yaml` or the name of the file in a tasks dir.
 type SharedTask struct {
 	Id     string  `yaml:"id,omitempty"`
 	Desc   *string `yaml:"desc,omitempty"`
@@ -93,6 +132,8 @@ type Output struct {
 	Type    *string `yaml:"type,omitempty"`
 }
 
+// Input is a value of with accepted by a shared task. The type is string,
+// number, integer or boolean, string by default.
 type Input struct {
 	Id       string  `yaml:"id,omitempty"`
 	Name     *string `yaml:"name,omitempty"`
@@ -102,3 +143,11 @@ type Input struct {
 	Type     *string `yaml:"type,omitempty"`
 	Required *bool   `yaml:"required,omitempty"`
 }
+
+// Param is a command line flag of a task. The flag is the id of the input,
+// e.g. --tag, and short is its one letter shorthand.
+type Param struct {
+	Input `yaml:",inline"`
+	Short *string  `yaml:"short,omitempty"`
+	Enum  []string `yaml:"enum,omitempty"`
+}
@@ -1,27 +1,38 @@
 package types
 
 import (
+	"errors"
 	"maps"
+	"slices"
 
 	"gopkg.in/yaml.v3"
 )
 
 type XHostFile struct {
 	Path     string                       `yaml:"path"`
-	Hosts    Hosts                        `yaml:"host"`
+	Hosts    Hosts                        `yaml:"hosts"`
 	Defaults map[string]XHostfileDefaults `yaml:"defaults,omitempty"`
 	Imports  []string                     `yaml:"imports,omitempty"`
 }
 
+// XHostfileDefaults are the fields a host gets when it does not set them.
+// Hosts use the defaults named by their defaults field, or "default".
 type XHostfileDefaults struct {
 	Port     *int    `yaml:"port,omitempty"`
 	User     *string `yaml:"user,omitempty"`
 	Identity *string `yaml:"identity,omitempty"`
 	// this must point to an env variable that contains the password
-	Password *string                `yaml:"password,omitempty"`
-	Groups   []string               `yaml:"groups,omitempty"`
-	Meta     map[string]interface{} `yaml:"meta,omitempty"`
-	OS       *OS                    `yaml:"os,omitempty"`
+	Password       *string                `yaml:"password,omitempty"`
+	Groups         []string               `yaml:"groups,omitempty"`
+	Meta           map[string]interface{} `yaml:"meta,omitempty"`
+	OS             *OS                    `yaml:"os,omitempty"`
+	HostKeyCheck   *string                `yaml:"host-key-check,omitempty"`
+	KnownHosts     *string                `yaml:"known-hosts,omitempty"`
+	ProxyJump      *string                `yaml:"proxy-jump,omitempty"`
+	Become         *bool                  `yaml:"become,omitempty"`
+	BecomeUser     *string                `yaml:"become-user,omitempty"`
+	BecomeMethod   *string                `yaml:"become-method,omitempty"`
+	BecomePassword *string                `yaml:"become-password,omitempty"`
 }
 
 func (f *XHostFile) Decode(data []byte) error {
@@ -34,101 +45,129 @@ func (f *XHostFile) Decode(data []byte) error {
 		return err
 	}
 
-	for _, v := range f.Hosts {
-		defaultName := "default"
-		if v.Defaults != "" {
-			defaultName = v.Defaults
+	for alias, host := range f.Hosts {
+		name := "default"
+		if host.Defaults != "" {
+			name = host.Defaults
 		}
 
-		if def, ok := f.Defaults[defaultName]; ok {
-			if v.Port == nil && def.Port != nil {
-				v.Port = def.Port
-			}
-			if v.User == nil && def.User != nil {
-				v.User = def.User
-			}
-			if v.Identity == nil && def.Identity != nil {
-				v.Identity = def.Identity
-			}
-			if v.Password == nil && def.Password != nil {
-				v.Password = def.Password
-			}
-			if len(v.Groups) == 0 && len(def.Groups) > 0 {
-				v.Groups = def.Groups
+		def, ok := f.Defaults[name]
+		if !ok {
+			if host.Defaults != "" {
+				return errors.New("unknown defaults " + host.Defaults + " for host " + alias)
 			}
+			continue
+		}
 
-			meta := make(map[string]interface{})
-			if def.Meta != nil {
-				maps.Copy(meta, def.Meta)
-			}
+		f.Hosts[alias] = def.Apply(host)
+	}
 
-			if v.Meta != nil {
-				maps.Copy(meta, v.Meta)
-			}
-			v.Meta = meta
-
-			os := &OS{}
-			if def.OS != nil {
-				os.Arch = def.OS.Arch
-				os.Family = def.OS.Family
-				os.BuildVersion = def.OS.BuildVersion
-				os.Codename = def.OS.Codename
-				os.Platform = def.OS.Platform
-				os.Variant = def.OS.Variant
-				os.Version = def.OS.Version
-			}
+	return nil
+}
 
-			if v.OS != nil {
-				if v.OS.Arch != "" {
-					os.Arch = v.OS.Arch
-				}
-				if v.OS.Family != "" {
-					os.Family = v.OS.Family
-				}
-				if v.OS.BuildVersion != "" {
-					os.BuildVersion = v.OS.BuildVersion
-				}
-				if v.OS.Codename != "" {
-					os.Codename = v.OS.Codename
-				}
-				if v.OS.Platform != "" {
-					os.Platform = v.OS.Platform
-				}
-				if v.OS.Variant != "" {
-					os.Variant = v.OS.Variant
-				}
-				if v.OS.Version != "" {
-					os.Version = v.OS.Version
+// Apply returns host with the fields it does not set taken from the
+// defaults. Groups are joined, meta and os are merged with the values of
+// the host taking precedence.
+func (d XHostfileDefaults) Apply(host Host) Host {
+	pick := func(v *string, def *string) *string {
+		if v == nil {
+			return def
+		}
+		return v
+	}
+
+	if host.Port == nil {
+		host.Port = d.Port
+	}
+
+	if host.Become == nil {
+		host.Become = d.Become
+	}
+
+	host.User = pick(host.User, d.User)
+	host.Identity = pick(host.Identity, d.Identity)
+	host.Password = pick(host.Password, d.Password)
+	host.HostKeyCheck = pick(host.HostKeyCheck, d.HostKeyCheck)
+	host.KnownHosts = pick(host.KnownHosts, d.KnownHosts)
+	host.ProxyJump = pick(host.ProxyJump, d.ProxyJump)
+	host.BecomeUser = pick(host.BecomeUser, d.BecomeUser)
+	host.BecomeMethod = pick(host.BecomeMethod, d.BecomeMethod)
+	host.BecomePassword = pick(host.BecomePassword, d.BecomePassword)
+
+	groups := slices.Clone(d.Groups)
+	for _, group := range host.Groups {
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	host.Groups = groups
+
+	if d.Meta != nil || host.Meta != nil {
+		meta := make(map[string]interface{})
+		maps.Copy(meta, d.Meta)
+		maps.Copy(meta, host.Meta)
+		host.Meta = meta
+	}
+
+	if d.OS != nil {
+		os := *d.OS
+		if host.OS != nil {
+			for _, field := range []struct{ dst, src *string }{
+				{&os.Platform, &host.OS.Platform},
+				{&os.Arch, &host.OS.Arch},
+				{&os.Variant, &host.OS.Variant},
+				{&os.Family, &host.OS.Family},
+				{&os.Codename, &host.OS.Codename},
+				{&os.Version, &host.OS.Version},
+				{&os.BuildVersion, &host.OS.BuildVersion},
+			} {
+				if *field.src != "" {
+					*field.dst = *field.src
 				}
 			}
 		}
+		host.OS = &os
 	}
 
-	return nil
+	return host
 }
 
+// UnmarshalYAML reads a hostfile. A document without any of the hostfile
+// keys is read as the hosts, e.g. the output of an inventory script.
 func (f *XHostFile) UnmarshalYAML(node *yaml.Node) error {
 
 	if node.Kind != yaml.MappingNode {
 		return nil
 	}
 
+	if f.Hosts == nil {
+		f.Hosts = Hosts{}
+	}
+
+	if f.Defaults == nil {
+		f.Defaults = map[string]XHostfileDefaults{}
+	}
+
+	known := false
 	for i := 0; i < len(node.Content); i += 2 {
 		keyNode := node.Content[i]
 		valNode := node.Content[i+1]
 
 		switch keyNode.Value {
 		case "path":
+			known = true
 			if valNode.Kind == yaml.ScalarNode {
 				f.Path = valNode.Value
 			}
-		case "host":
-			if valNode.Kind == yaml.MappingNode {
+		case "hosts", "host":
+			known = true
+			if valNode.Kind == yaml.MappingNode || valNode.Kind == yaml.SequenceNode {
 				if err := valNode.Decode(&f.Hosts); err != nil {
 					return err
 				}
 			}
 		case "default":
+			known = true
 			if valNode.Kind == yaml.MappingNode {
 				defaultDefaults := &XHostfileDefaults{}
 				if err := valNode.Decode(defaultDefaults); err != nil {
@@ -137,12 +176,14 @@ func (f *XHostFile) UnmarshalYAML(node *yaml.Node) error {
 				f.Defaults["default"] = *defaultDefaults
 			}
 		case "defaults":
+			known = true
 			if valNode.Kind == yaml.MappingNode {
 				if err := valNode.Decode(&f.Defaults); err != nil {
 					return err
 				}
 			}
 		case "imports":
+			known = true
 			if valNode.Kind == yaml.SequenceNode {
 				if err := valNode.Decode(&f.Imports); err != nil {
 					return err
@@ -151,5 +192,9 @@ func (f *XHostFile) UnmarshalYAML(node *yaml.Node) error {
 		}
 	}
 
+	if !known {
+		return node.Decode(&f.Hosts)
+	}
+
 	return nil
 }
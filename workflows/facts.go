@@ -0,0 +1,108 @@
+package workflows
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// needsFacts reports whether the os of the hosts of task is used, either
+// because facts are always gathered or because the task filters or tests
+// the os of its hosts.
+func (ws *Workflow) needsFacts(task types.Task) bool {
+	if ws.Config != nil && ws.Config.SSH.GatherFacts {
+		return true
+	}
+
+	if len(task.OS) > 0 {
+		return true
+	}
+
+	return task.Predicate != nil && strings.Contains(*task.Predicate, ".host.os")
+}
+
+// gatherFacts sets the os of the hosts that do not declare one. The facts
+// of a host are gathered once per run, the hosts are asked at the same
+// time.
+func (ws *Workflow) gatherFacts(state *runState, taskEnv *types.Env, hosts map[string]types.Host) error {
+	ctx := tasks.TaskContext{
+		Context:   ws.Context,
+		Data:      tasks.TaskData{Env: *taskEnv},
+		Inventory: ws.Hosts,
+		SSHPool:   state.sshPool,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	gathered := map[string]*types.OS{}
+	failed := []string{}
+
+	for alias, host := range hosts {
+		if host.OS != nil {
+			continue
+		}
+
+		state.mu.Lock()
+		facts, ok := state.facts[alias]
+		state.mu.Unlock()
+		if ok {
+			gathered[alias] = facts
+			continue
+		}
+
+		wg.Add(1)
+		go func(alias string, host types.Host) {
+			defer wg.Done()
+
+			facts, err := tasks.GatherFacts(ctx, alias, host)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, alias+": "+err.Error())
+				return
+			}
+
+			gathered[alias] = facts
+		}(alias, host)
+	}
+
+	wg.Wait()
+
+	state.mu.Lock()
+	for alias, facts := range gathered {
+		state.facts[alias] = facts
+		host := hosts[alias]
+		host.OS = facts
+		hosts[alias] = host
+	}
+	state.mu.Unlock()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return errors.New(strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// filterHostsByOS removes the hosts whose os matches none of names.
+func filterHostsByOS(hosts map[string]types.Host, names []string) {
+	for alias, host := range hosts {
+		match := false
+		for _, name := range names {
+			if host.OS.Matches(name) {
+				match = true
+				break
+			}
+		}
+
+		if !match {
+			delete(hosts, alias)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package workflows
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterHostsByOS(t *testing.T) {
+	hosts := map[string]types.Host{
+		"web1":  {Host: "10.0.0.1", OS: &types.OS{Platform: "linux", Family: "debian", Variant: "ubuntu"}},
+		"db1":   {Host: "10.0.0.2", OS: &types.OS{Platform: "linux", Family: "rhel", Variant: "rocky"}},
+		"mac1":  {Host: "10.0.0.3", OS: &types.OS{Platform: "darwin", Variant: "macos"}},
+		"other": {Host: "10.0.0.4"},
+	}
+
+	filterHostsByOS(hosts, []string{"Debian", "macos"})
+	assert.Len(t, hosts, 2)
+	assert.Contains(t, hosts, "web1")
+	assert.Contains(t, hosts, "mac1")
+}
+
+func TestNeedsFactsFromXTaskfile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "xtaskfile"), `
+config:
+  ssh:
+    gather-facts: true
+tasks:
+  deploy:
+    uses: ssh
+    hosts: [web]
+    run: uptime
+`, 0644)
+
+	tf := types.NewXTaskfile()
+	assert.NoError(t, tf.DecodeYAMLFile(filepath.Join(dir, "xtaskfile")))
+
+	t.Setenv("XTASK_ENV", filepath.Join(dir, "env"))
+	t.Setenv("XTASK_PATH", filepath.Join(dir, "path"))
+	wf := NewWorkflow()
+	assert.NoError(t, wf.Load(*tf))
+
+	assert.True(t, wf.needsFacts(wf.Tasks["deploy"]))
+	assert.False(t, NewWorkflow().needsFacts(types.Task{Id: "deploy", Hosts: []string{"web"}}))
+}
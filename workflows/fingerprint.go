@@ -0,0 +1,144 @@
+package workflows
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/paths"
+	"github.com/hyprxlabs/xtask/types"
+	"gopkg.in/yaml.v3"
+)
+
+// fingerprint is used to skip a task with sources or generates when
+// nothing it depends on changed since its last successful run. The
+// outputs of that run are kept so that a skipped task still has them.
+type fingerprint struct {
+	file    string
+	hash    string
+	outputs map[string]interface{}
+}
+
+// fingerprintFile is the content of the file of a fingerprint.
+type fingerprintFile struct {
+	Hash    string                 `yaml:"hash"`
+	Outputs map[string]interface{} `yaml:"outputs,omitempty"`
+}
+
+// newFingerprint hashes the source files of the task together with the
+// rendered run script, uses, with, args, the values of the env vars
+// declared by the task and the INPUT_, PARAM_ and MATRIX_ values. The hash is
+// stored in a file under cacheDir named after the xtaskfile and the task
+// id.
+func newFingerprint(cacheDir string, xtaskfile string, task types.Task, cwd string, run string, uses string, args []string, taskEnv *types.Env) (*fingerprint, error) {
+	h := sha256.New()
+	io.WriteString(h, "uses\x00"+uses+"\x00")
+	io.WriteString(h, "run\x00"+run+"\x00")
+	for _, arg := range args {
+		io.WriteString(h, "arg\x00"+arg+"\x00")
+	}
+
+	if len(task.With) > 0 {
+		// yaml sorts the keys of maps, so the same values give the same hash.
+		with, err := yaml.Marshal(task.With)
+		if err != nil {
+			return nil, errors.New("failed to hash with for task " + task.Id + ": " + err.Error())
+		}
+		io.WriteString(h, "with\x00"+string(with)+"\x00")
+	}
+
+	keys := task.Env.Keys()
+	for _, key := range taskEnv.Keys() {
+		if strings.HasPrefix(key, "INPUT_") || strings.HasPrefix(key, "PARAM_") || strings.HasPrefix(key, "MATRIX_") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range slices.Compact(keys) {
+		io.WriteString(h, "env\x00"+key+"="+taskEnv.GetString(key)+"\x00")
+	}
+
+	files, err := paths.Glob(cwd, task.Sources)
+	if err != nil {
+		return nil, errors.New("failed to glob sources for task " + task.Id + ": " + err.Error())
+	}
+
+	for _, file := range files {
+		sum, err := hashFile(filepath.Join(cwd, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, errors.New("failed to hash source " + file + " for task " + task.Id + ": " + err.Error())
+		}
+
+		io.WriteString(h, "source\x00"+file+"\x00"+sum+"\x00")
+	}
+
+	key := sha256.Sum256([]byte(xtaskfile + "\x00" + task.Id))
+	return &fingerprint{
+		file: filepath.Join(cacheDir, "fingerprints", hex.EncodeToString(key[:])),
+		hash: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// upToDate reports whether the stored hash matches and every generates
+// pattern, other than the excludes, matches at least one file. The stored
+// outputs are read into f.outputs.
+func (f *fingerprint) upToDate(cwd string, generates []string) bool {
+	data, err := os.ReadFile(f.file)
+	if err != nil {
+		return false
+	}
+
+	stored := fingerprintFile{}
+	if err := yaml.Unmarshal(data, &stored); err != nil || stored.Hash != f.hash {
+		return false
+	}
+	f.outputs = stored.Outputs
+
+	for _, pattern := range generates {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+
+		files, err := paths.Glob(cwd, []string{pattern})
+		if err != nil || len(files) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// save stores the hash with the outputs of the run of the task.
+func (f *fingerprint) save(outputs map[string]interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(f.file), 0755); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(fingerprintFile{Hash: f.hash, Outputs: outputs})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.file, data, 0644)
+}
+
+func hashFile(file string) (string, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
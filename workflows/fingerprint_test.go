@@ -0,0 +1,105 @@
+package workflows
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprintUpToDate(t *testing.T) {
+	cwd := t.TempDir()
+	cache := t.TempDir()
+	src := filepath.Join(cwd, "src.txt")
+	assert.NoError(t, os.WriteFile(src, []byte("a"), 0644))
+
+	task := newTestTask("gen")
+	task.Sources = []string{"*.txt"}
+	task.Generates = []string{"out/*"}
+	taskEnv := types.NewEnv()
+
+	fp, err := newFingerprint(cache, "xtaskfile", task, cwd, "gen", "bash", nil, taskEnv)
+	assert.NoError(t, err)
+	assert.False(t, fp.upToDate(cwd, task.Generates))
+	assert.NoError(t, fp.save(nil))
+
+	// generated files are missing.
+	assert.False(t, fp.upToDate(cwd, task.Generates))
+	assert.NoError(t, os.MkdirAll(filepath.Join(cwd, "out"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(cwd, "out", "gen"), []byte("a"), 0644))
+	assert.True(t, fp.upToDate(cwd, task.Generates))
+
+	assert.NoError(t, os.WriteFile(src, []byte("b"), 0644))
+	fp, err = newFingerprint(cache, "xtaskfile", task, cwd, "gen", "bash", nil, taskEnv)
+	assert.NoError(t, err)
+	assert.False(t, fp.upToDate(cwd, task.Generates))
+
+	// the run script is part of the fingerprint.
+	assert.NoError(t, fp.save(nil))
+	fp, err = newFingerprint(cache, "xtaskfile", task, cwd, "gen --all", "bash", nil, taskEnv)
+	assert.NoError(t, err)
+	assert.False(t, fp.upToDate(cwd, task.Generates))
+
+	// the outputs of the last run are restored with the fingerprint.
+	assert.NoError(t, fp.save(map[string]interface{}{"version": "1.2.0", "count": 3}))
+	fp, err = newFingerprint(cache, "xtaskfile", task, cwd, "gen --all", "bash", nil, taskEnv)
+	assert.NoError(t, err)
+	assert.True(t, fp.upToDate(cwd, task.Generates))
+	assert.Equal(t, map[string]interface{}{"version": "1.2.0", "count": 3}, fp.outputs)
+
+	// inputs, params, matrix values, args and with are part of the
+	// fingerprint.
+	changes := []func(){
+		func() { taskEnv.Set("INPUT_PROJECT", "app.csproj") },
+		func() { taskEnv.Set("PARAM_TARGET", "b") },
+		func() { taskEnv.Set("MATRIX_OS", "linux") },
+		func() { task.With = map[string]interface{}{"mode": "fast"} },
+	}
+	for _, change := range changes {
+		change()
+		fp, err = newFingerprint(cache, "xtaskfile", task, cwd, "gen --all", "bash", nil, taskEnv)
+		assert.NoError(t, err)
+		assert.False(t, fp.upToDate(cwd, task.Generates))
+		assert.NoError(t, fp.save(nil))
+	}
+
+	fp, err = newFingerprint(cache, "xtaskfile", task, cwd, "gen --all", "bash", []string{"--verbose"}, taskEnv)
+	assert.NoError(t, err)
+	assert.False(t, fp.upToDate(cwd, task.Generates))
+}
+
+func TestRunReplaysTaskWhenParamChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "src.txt"), "a", 0644)
+	writeFile(t, filepath.Join(dir, "xtaskfile"), `
+tasks:
+  gen:
+    uses: bash
+    params:
+      - id: target
+    sources: ["*.txt"]
+    generates: ["out/*"]
+    run: |
+      mkdir -p out
+      echo "${PARAM_TARGET}" >> out/runs
+`, 0644)
+
+	tf := types.NewXTaskfile()
+	assert.NoError(t, tf.DecodeYAMLFile(filepath.Join(dir, "xtaskfile")))
+
+	t.Setenv("XTASK_ENV", filepath.Join(dir, "env"))
+	t.Setenv("XTASK_PATH", filepath.Join(dir, "path"))
+	t.Setenv("XTASK_CACHE_HOME", filepath.Join(dir, "cache"))
+	wf := NewWorkflow()
+	assert.NoError(t, wf.Load(*tf))
+
+	for _, target := range []string{"a", "a", "b"} {
+		assert.NoError(t, wf.Run([]string{"gen"}, []string{"--target", target}))
+	}
+
+	runs, err := os.ReadFile(filepath.Join(dir, "out", "runs"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(runs))
+}
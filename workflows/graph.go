@@ -6,36 +6,145 @@ import (
 	"github.com/hyprxlabs/xtask/types"
 )
 
-func flattenTasks(targets []string, tasks types.Tasks, set []types.Task) ([]types.Task, error) {
+// taskNode is a single task in the dependency graph built for a run.
+type taskNode struct {
+	task       types.Task
+	index      int
+	needs      []*taskNode
+	dependents []*taskNode
+}
+
+// taskGraph holds the tasks required to run a set of targets. The nodes
+// are kept in the order the tasks would run when executed one at a time.
+type taskGraph struct {
+	nodes []*taskNode
+	index map[string]*taskNode
+}
 
+// buildTaskGraph resolves the targets and all of their needs into a graph.
+//
+// Every need becomes an edge from the task to the needed task. Needs are
+// also ordered against their siblings so that `needs: [clean, build]` still
+// runs clean before build; a need marked with `async: true` opts out of the
+// ordering and may run alongside the siblings declared before it. The
+// targets themselves are ordered the same way.
+func buildTaskGraph(targets []string, tasks types.Tasks) (*taskGraph, error) {
+	g := &taskGraph{
+		nodes: []*taskNode{},
+		index: map[string]*taskNode{},
+	}
+
+	roots := types.Needs{}
 	for _, target := range targets {
-		task, ok := tasks[target]
-		if !ok {
-			return nil, errors.New("Task not found: " + target)
-		}
+		roots = append(roots, types.Need{Name: target})
+	}
 
-		if len(task.Needs) > 0 {
-			neededTasks, err := flattenTasks(task.Needs, tasks, set)
-			if err != nil {
-				return nil, err
+	var visit func(needs types.Needs) error
+	visit = func(needs types.Needs) error {
+		for _, need := range needs {
+			if _, ok := g.index[need.Name]; ok {
+				continue
+			}
+
+			task, ok := tasks[need.Name]
+			if !ok {
+				return errors.New("Task not found: " + need.Name)
+			}
+
+			if len(task.Needs) > 0 {
+				if err := visit(task.Needs); err != nil {
+					return err
+				}
+			}
+
+			// a task may have been added while visiting its needs if
+			// the needs are cyclical.
+			if _, ok := g.index[need.Name]; ok {
+				continue
 			}
-			set = neededTasks
+
+			node := &taskNode{task: task, index: len(g.nodes)}
+			g.nodes = append(g.nodes, node)
+			g.index[need.Name] = node
 		}
 
-		added := false
-		for _, task2 := range set {
-			if task.Id == task2.Id {
-				added = true
-				break
+		return nil
+	}
+
+	if err := visit(roots); err != nil {
+		return nil, err
+	}
+
+	for _, node := range g.nodes {
+		for _, need := range node.task.Needs {
+			g.link(node, g.index[need.Name])
+		}
+	}
+
+	g.order(roots)
+	for _, node := range g.nodes {
+		g.order(node.task.Needs)
+	}
+
+	return g, nil
+}
+
+// order adds the sibling ordering edges for a list of needs. An edge is
+// skipped when it would introduce a cycle, e.g. when two tasks list the
+// same needs in a different order.
+func (g *taskGraph) order(needs types.Needs) {
+	for i, need := range needs {
+		if need.Parallel {
+			continue
+		}
+
+		node := g.index[need.Name]
+		for _, prev := range needs[:i] {
+			before := g.index[prev.Name]
+			if before == node || g.dependsOn(before, node) {
+				continue
 			}
+
+			g.link(node, before)
 		}
+	}
+}
 
-		if !added {
-			set = append(set, task)
+func (g *taskGraph) link(node *taskNode, need *taskNode) {
+	if node == nil || need == nil {
+		return
+	}
+
+	for _, n := range node.needs {
+		if n == need {
+			return
+		}
+	}
+
+	node.needs = append(node.needs, need)
+	need.dependents = append(need.dependents, node)
+}
+
+// dependsOn reports whether node transitively needs target.
+func (g *taskGraph) dependsOn(node *taskNode, target *taskNode) bool {
+	seen := map[*taskNode]bool{}
+	stack := []*taskNode{node}
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, n := range next.needs {
+			if n == target {
+				return true
+			}
+
+			if !seen[n] {
+				seen[n] = true
+				stack = append(stack, n)
+			}
 		}
 	}
 
-	return set, nil
+	return false
 }
 
 func findCyclicalReferences(tasks []types.Task) []types.Task {
@@ -55,7 +164,7 @@ func findCyclicalReferences(tasks []types.Task) []types.Task {
 		if len(task.Needs) > 0 {
 			for _, need := range task.Needs {
 				for _, nextTask := range tasks {
-					if nextTask.Id == need {
+					if nextTask.Id == need.Name {
 						if !resolve(nextTask) {
 							return false
 						}
@@ -0,0 +1,147 @@
+package workflows
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTask(id string, needs ...types.Need) types.Task {
+	run := "echo " + id
+	return types.Task{Id: id, Run: &run, Needs: needs}
+}
+
+func graphIds(g *taskGraph) []string {
+	ids := []string{}
+	for _, node := range g.nodes {
+		ids = append(ids, node.task.Id)
+	}
+	return ids
+}
+
+func TestBuildTaskGraphOrder(t *testing.T) {
+	tasks := types.Tasks{
+		"clean":   newTestTask("clean"),
+		"restore": newTestTask("restore"),
+		"build":   newTestTask("build", types.Need{Name: "clean"}, types.Need{Name: "restore"}),
+		"test":    newTestTask("test", types.Need{Name: "build"}),
+	}
+
+	g, err := buildTaskGraph([]string{"test"}, tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"clean", "restore", "build", "test"}, graphIds(g))
+
+	// restore is ordered after clean because neither need is async.
+	assert.True(t, g.dependsOn(g.index["restore"], g.index["clean"]))
+}
+
+func TestBuildTaskGraphAsyncNeeds(t *testing.T) {
+	tasks := types.Tasks{
+		"lint": newTestTask("lint"),
+		"vet":  newTestTask("vet"),
+		"ci": newTestTask("ci",
+			types.Need{Name: "lint", Parallel: true},
+			types.Need{Name: "vet", Parallel: true}),
+	}
+
+	g, err := buildTaskGraph([]string{"ci"}, tasks)
+	assert.NoError(t, err)
+	assert.False(t, g.dependsOn(g.index["vet"], g.index["lint"]))
+	assert.True(t, g.dependsOn(g.index["ci"], g.index["vet"]))
+}
+
+func TestBuildTaskGraphConflictingOrder(t *testing.T) {
+	tasks := types.Tasks{
+		"a":   newTestTask("a"),
+		"b":   newTestTask("b"),
+		"one": newTestTask("one", types.Need{Name: "a"}, types.Need{Name: "b"}),
+		"two": newTestTask("two", types.Need{Name: "b"}, types.Need{Name: "a"}),
+	}
+
+	g, err := buildTaskGraph([]string{"one", "two"}, tasks)
+	assert.NoError(t, err)
+
+	done := []string{}
+	err = schedule(g, 1, func(node *taskNode) error {
+		done = append(done, node.task.Id)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "one", "two"}, done)
+}
+
+func TestBuildTaskGraphMissingTask(t *testing.T) {
+	tasks := types.Tasks{
+		"build": newTestTask("build", types.Need{Name: "missing"}),
+	}
+
+	_, err := buildTaskGraph([]string{"build"}, tasks)
+	assert.Error(t, err)
+}
+
+func TestScheduleRunsIndependentTasksConcurrently(t *testing.T) {
+	needs := types.Needs{}
+	tasks := types.Tasks{}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		tasks[id] = newTestTask(id)
+		needs = append(needs, types.Need{Name: id, Parallel: true})
+	}
+	tasks["all"] = newTestTask("all", needs...)
+
+	g, err := buildTaskGraph([]string{"all"}, tasks)
+	assert.NoError(t, err)
+
+	var running, peak int32
+	var mu sync.Mutex
+	done := []string{}
+	err = schedule(g, 2, func(node *taskNode) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		mu.Lock()
+		done = append(done, node.task.Id)
+		mu.Unlock()
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), peak)
+	assert.Len(t, done, 5)
+	assert.Equal(t, "all", done[len(done)-1])
+}
+
+func TestScheduleStopsOnError(t *testing.T) {
+	tasks := types.Tasks{
+		"a":   newTestTask("a"),
+		"b":   newTestTask("b", types.Need{Name: "a"}),
+		"all": newTestTask("all", types.Need{Name: "b"}),
+	}
+
+	g, err := buildTaskGraph([]string{"all"}, tasks)
+	assert.NoError(t, err)
+
+	done := []string{}
+	err = schedule(g, 4, func(node *taskNode) error {
+		done = append(done, node.task.Id)
+		if node.task.Id == "b" {
+			return errors.New("b failed")
+		}
+		return nil
+	})
+
+	assert.EqualError(t, err, "b failed")
+	assert.Equal(t, []string{"a", "b"}, done)
+}
@@ -0,0 +1,137 @@
+package workflows
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hyprxlabs/go/env"
+	"github.com/hyprxlabs/go/exec"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// hostImporter loads the hosts of hostfile imports. An import may be a
+// hostfile or an inventory script that prints hosts as YAML or JSON.
+type hostImporter struct {
+	env          *types.Env
+	substitution bool
+	// loading is the chain of files being imported, used to detect cycles.
+	loading []string
+	loaded  map[string]bool
+}
+
+// importAll adds the hosts of imports to hosts. Relative imports are
+// resolved from dir and an import that ends with ? is optional. Later
+// imports take precedence over earlier ones.
+func (hi *hostImporter) importAll(imports []string, dir string, hosts types.Hosts) error {
+	for _, imp := range imports {
+		opts := &env.ExpandOptions{
+			Get:                 hi.env.GetString,
+			Set:                 func(key, value string) error { hi.env.Set(key, value); return nil },
+			Keys:                hi.env.Keys(),
+			ExpandUnixArgs:      true,
+			ExpandWindowsVars:   false,
+			CommandSubstitution: hi.substitution,
+		}
+
+		next, err := env.ExpandWithOptions(imp, opts)
+		if err != nil {
+			return errors.New("failed to expand hosts import path: " + imp + " error: " + err.Error())
+		}
+
+		next = strings.TrimSpace(next)
+		optional := false
+		if strings.HasSuffix(next, "?") {
+			optional = true
+			next = strings.TrimSuffix(next, "?")
+		}
+
+		if !filepath.IsAbs(next) {
+			next = filepath.Join(dir, next)
+		}
+		next = filepath.Clean(next)
+
+		info, err := os.Stat(next)
+		if err != nil || info.IsDir() {
+			if optional {
+				continue
+			}
+
+			return errors.New("required hosts import file does not exist: " + next)
+		}
+
+		if slices.Contains(hi.loading, next) {
+			return errors.New("hosts import cycle: " + strings.Join(append(hi.loading, next), " -> "))
+		}
+
+		// a file imported by several hostfiles is only loaded once.
+		if hi.loaded[next] {
+			continue
+		}
+
+		hi.loading = append(hi.loading, next)
+		err = hi.importFile(next, info, hosts)
+		hi.loading = hi.loading[:len(hi.loading)-1]
+		hi.loaded[next] = true
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importFile adds the hosts of a hostfile or inventory script. The imports
+// of the file are loaded first so that its own hosts take precedence.
+func (hi *hostImporter) importFile(file string, info os.FileInfo, hosts types.Hosts) error {
+	var data []byte
+	var err error
+	if isInventoryScript(file, info) {
+		out := &bytes.Buffer{}
+		cmd := exec.New(file).
+			WithCwd(filepath.Dir(file)).
+			WithEnvMap(hi.env.ToMap()).
+			WithStdout(out).
+			WithStderr(os.Stderr)
+		if err = cmd.Start(); err == nil {
+			err = cmd.Wait()
+		}
+
+		if err != nil {
+			return errors.New("failed to run hosts inventory: " + file + " error: " + err.Error())
+		}
+		data = out.Bytes()
+	} else {
+		data, err = os.ReadFile(file)
+		if err != nil {
+			return errors.New("failed to read hosts import file: " + file + " error: " + err.Error())
+		}
+	}
+
+	var hostfile types.XHostFile
+	if err := hostfile.Decode(data); err != nil {
+		return errors.New("failed to parse hosts import file: " + file + " error: " + err.Error())
+	}
+
+	if err := hi.importAll(hostfile.Imports, filepath.Dir(file), hosts); err != nil {
+		return err
+	}
+
+	maps.Copy(hosts, hostfile.Hosts)
+	return nil
+}
+
+// isInventoryScript reports whether an import is run instead of read. Files
+// with a yaml or json extension are always read.
+func isInventoryScript(file string, info os.FileInfo) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return false
+	}
+
+	return isExecutable(file, info)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package workflows
+
+import "os"
+
+func isExecutable(file string, info os.FileInfo) bool {
+	return info.Mode().Perm()&0111 != 0
+}
@@ -0,0 +1,97 @@
+package workflows
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostImporterNestedImportsAndDefaults(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hosts.yaml"), `
+imports: ["./prod/web.yaml", "missing.yaml?"]
+defaults:
+  default:
+    user: ops
+    groups: [all-prod]
+    meta: { team: web, region: us }
+    os: { platform: linux, family: debian }
+hosts:
+  web-1:
+    host: 10.0.0.1
+    groups: [web]
+    meta: { region: eu }
+    os: { variant: ubuntu }
+  db-1:
+    host: admin@10.0.0.5:2222
+`, 0644)
+
+	writeFile(t, filepath.Join(dir, "prod", "web.yaml"), `
+imports: ["../shared/bastion.yaml"]
+host:
+  web-1: 10.9.9.9
+  web-2: 10.0.0.2
+`, 0644)
+
+	writeFile(t, filepath.Join(dir, "shared", "bastion.yaml"), `
+hosts:
+  - bastion.example.com
+`, 0644)
+
+	hosts := types.Hosts{}
+	importer := &hostImporter{env: types.NewEnv(), loaded: map[string]bool{}}
+	assert.NoError(t, importer.importAll([]string{"hosts.yaml"}, dir, hosts))
+
+	assert.Len(t, hosts, 4)
+	assert.Contains(t, hosts, "bastion.example.com")
+	assert.Equal(t, "10.0.0.2", hosts["web-2"].Host)
+
+	// the hosts of a file take precedence over its imports.
+	web := hosts["web-1"]
+	assert.Equal(t, "10.0.0.1", web.Host)
+	assert.Equal(t, "ops", *web.User)
+	assert.Equal(t, []string{"all-prod", "web"}, web.Groups)
+	assert.Equal(t, map[string]interface{}{"team": "web", "region": "eu"}, web.Meta)
+	assert.Equal(t, &types.OS{Platform: "linux", Family: "debian", Variant: "ubuntu"}, web.OS)
+
+	db := hosts["db-1"]
+	assert.Equal(t, "admin", *db.User)
+	assert.Equal(t, 2222, *db.Port)
+}
+
+func TestHostImporterCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "imports: [b.yaml]\nhosts: { a: 10.0.0.1 }\n", 0644)
+	writeFile(t, filepath.Join(dir, "b.yaml"), "imports: [a.yaml]\nhosts: { b: 10.0.0.2 }\n", 0644)
+
+	importer := &hostImporter{env: types.NewEnv(), loaded: map[string]bool{}}
+	err := importer.importAll([]string{"a.yaml"}, dir, types.Hosts{})
+	assert.ErrorContains(t, err, "hosts import cycle")
+}
+
+func TestHostImporterInventoryScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the inventory script is a shell script")
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "inventory"), `#!/bin/sh
+echo '{"app-1": {"host": "10.1.0.1", "meta": {"env": "'"$INVENTORY_ENV"'"}}, "app-2": "deploy@10.1.0.2"}'
+`, 0755)
+
+	e := types.NewEnv()
+	e.Set("INVENTORY_ENV", "prod")
+	e.Set("PATH", os.Getenv("PATH"))
+
+	hosts := types.Hosts{}
+	importer := &hostImporter{env: e, loaded: map[string]bool{}}
+	assert.NoError(t, importer.importAll([]string{"./inventory"}, dir, hosts))
+
+	assert.Equal(t, "10.1.0.1", hosts["app-1"].Host)
+	assert.Equal(t, "prod", hosts["app-1"].Meta["env"])
+	assert.Equal(t, "deploy", *hosts["app-2"].User)
+}
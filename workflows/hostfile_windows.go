@@ -0,0 +1,18 @@
+//go:build windows
+
+package workflows
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func isExecutable(file string, info os.FileInfo) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".exe", ".bat", ".cmd", ".com":
+		return true
+	}
+
+	return false
+}
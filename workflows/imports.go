@@ -0,0 +1,315 @@
+package workflows
+
+import (
+	"errors"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hyprxlabs/go/env"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// taskfileImporter merges the xtaskfiles imported by an xtaskfile.
+type taskfileImporter struct {
+	env          *types.Env
+	substitution bool
+	remote       *remoteImports
+	// loading is the chain of files being imported, used to detect cycles.
+	loading []string
+}
+
+// resolveImports merges the imports of taskfile into it and clears them so
+// that they are only merged once. The tasks of an import with a namespace
+// are prefixed with it, e.g. lint:go, and the values of taskfile take
+// precedence over the values of its imports. Remote imports are fetched
+// with remote.
+func resolveImports(taskfile *types.XTaskfile, remote *remoteImports) error {
+	if len(taskfile.Imports) == 0 || taskfile.Path == "" {
+		return nil
+	}
+
+	path, err := filepath.Abs(taskfile.Path)
+	if err != nil {
+		return err
+	}
+
+	envMap := types.NewEnv()
+	for _, n := range os.Environ() {
+		key, value, _ := strings.Cut(n, "=")
+		envMap.Set(key, value)
+	}
+
+	substitution := taskfile.Config == nil || taskfile.Config.Substitution
+	ti := &taskfileImporter{env: envMap, substitution: substitution, remote: remote, loading: []string{path}}
+	return ti.importAll(taskfile, filepath.Dir(path))
+}
+
+// importAll merges the imports of taskfile into it. Relative imports are
+// resolved from dir, which is the url of a remote file, and later imports
+// take precedence over earlier ones.
+func (ti *taskfileImporter) importAll(taskfile *types.XTaskfile, dir string) error {
+	imports := taskfile.Imports
+	taskfile.Imports = types.Imports{}
+
+	merged := types.NewXTaskfile()
+	for _, imp := range imports {
+		file, base, ok, err := ti.resolve(imp, dir)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if slices.Contains(ti.loading, file) {
+			return errors.New("xtaskfile import cycle: " + strings.Join(append(ti.loading, file), " -> "))
+		}
+
+		imported := types.NewXTaskfile()
+		if err := imported.DecodeYAMLFile(file); err != nil {
+			return errors.New("failed to parse xtaskfile import: " + file + " error: " + err.Error())
+		}
+
+		ti.loading = append(ti.loading, file)
+		err = ti.importAll(imported, base)
+		ti.loading = ti.loading[:len(ti.loading)-1]
+		if err != nil {
+			return err
+		}
+
+		mergeTaskfile(merged, namespaced(imported, imp.Namespace, base))
+	}
+
+	mergeTaskfile(merged, taskfile)
+	taskfile.Tasks = merged.Tasks
+	taskfile.Env = merged.Env
+	taskfile.Dotenv = merged.Dotenv
+	taskfile.HostsNode = merged.HostsNode
+	taskfile.Values = merged.Values
+	if taskfile.Config != nil {
+		taskfile.Config.Env = merged.Config.Env
+		taskfile.Config.PrependPaths = merged.Config.PrependPaths
+		taskfile.Config.SSH = merged.Config.SSH
+	}
+
+	return nil
+}
+
+// resolve returns the absolute path of an import and the base its imports
+// are resolved from. An optional import that does not exist is not ok. The
+// uri may end with ? instead of setting optional.
+func (ti *taskfileImporter) resolve(imp types.Import, dir string) (string, string, bool, error) {
+	opts := &env.ExpandOptions{
+		Get:                 ti.env.GetString,
+		Set:                 func(key, value string) error { ti.env.Set(key, value); return nil },
+		Keys:                ti.env.Keys(),
+		ExpandUnixArgs:      true,
+		ExpandWindowsVars:   false,
+		CommandSubstitution: ti.substitution,
+	}
+
+	ti.env.Set("XTASK_DIR", dir)
+	next, err := env.ExpandWithOptions(imp.Uri, opts)
+	if err != nil {
+		return "", "", false, errors.New("failed to expand xtaskfile import: " + imp.Uri + " error: " + err.Error())
+	}
+
+	next = strings.TrimSpace(next)
+	optional := imp.Optional
+	if strings.HasSuffix(next, "?") {
+		optional = true
+		next = strings.TrimSuffix(next, "?")
+	}
+
+	if next == "" {
+		return "", "", false, errors.New("xtaskfile import is empty")
+	}
+
+	// relative imports of a remote file are remote as well.
+	if !strings.Contains(next, "://") && strings.Contains(dir, "://") && !filepath.IsAbs(next) {
+		base, err := url.Parse(dir)
+		if err != nil {
+			return "", "", false, errors.New("failed to parse xtaskfile import: " + dir + " error: " + err.Error())
+		}
+		next = base.JoinPath(filepath.ToSlash(next)).String()
+	}
+
+	if isRemoteImport(next) {
+		if ti.remote == nil {
+			return "", "", false, errors.New("remote xtaskfile imports are not supported here: " + next)
+		}
+
+		// a remote import that can not be fetched is an error even when it
+		// is optional, as the lock file would not pin it.
+		file, base, err := ti.remote.fetch(next)
+		if err != nil {
+			return "", "", false, err
+		}
+
+		return file, base, true, nil
+	}
+
+	if strings.Contains(next, "://") {
+		uri, err := url.Parse(next)
+		if err != nil {
+			return "", "", false, errors.New("failed to parse xtaskfile import: " + next + " error: " + err.Error())
+		}
+
+		if uri.Scheme != "file" {
+			return "", "", false, errors.New("unsupported xtaskfile import: " + next)
+		}
+
+		next = uri.Path
+	}
+
+	if !filepath.IsAbs(next) {
+		next = filepath.Join(dir, next)
+	}
+	next = filepath.Clean(next)
+
+	if !isFile(next) {
+		if optional {
+			return "", "", false, nil
+		}
+
+		return "", "", false, errors.New("required xtaskfile import does not exist: " + next)
+	}
+
+	return next, filepath.Dir(next), true, nil
+}
+
+// namespaced returns the tasks of an imported xtaskfile with their ids and
+// the needs that refer to them prefixed with ns. Relative paths of the
+// file are resolved from base so that they do not depend on the importer.
+func namespaced(imported *types.XTaskfile, ns string, base string) *types.XTaskfile {
+	abs := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "$") || strings.HasPrefix(p, "~") || strings.Contains(p, "://") {
+			return p
+		}
+
+		if u, err := url.Parse(base); err == nil && strings.Contains(base, "://") {
+			return u.JoinPath(filepath.ToSlash(p)).String()
+		}
+		return filepath.Join(base, p)
+	}
+
+	ns = strings.TrimSuffix(strings.TrimSpace(ns), ":")
+	id := func(name string) string {
+		if ns == "" {
+			return name
+		}
+		return ns + ":" + name
+	}
+
+	tasks := types.Tasks{}
+	if imported.Tasks != nil {
+		for name, task := range *imported.Tasks {
+			task.Id = id(name)
+
+			needs := make(types.Needs, 0, len(task.Needs))
+			for _, need := range task.Needs {
+				// needs that are not tasks of the import refer to the tasks
+				// of the importer.
+				if _, ok := (*imported.Tasks)[need.Name]; ok {
+					need.Name = id(need.Name)
+				}
+				needs = append(needs, need)
+			}
+			task.Needs = needs
+
+			if task.Run != nil {
+				run := strings.TrimSpace(*task.Run)
+				if !strings.ContainsAny(run, "\n\r") && (strings.HasSuffix(run, ".xtask.yaml") || strings.HasSuffix(run, ".xtask.yml")) {
+					run = abs(run)
+					task.Run = &run
+				}
+			}
+
+			if task.Uses != nil && isSharedTaskFile(*task.Uses) {
+				uses := abs(strings.TrimPrefix(*task.Uses, "file://"))
+				task.Uses = &uses
+			}
+
+			tasks[task.Id] = task
+		}
+	}
+	imported.Tasks = &tasks
+
+	dotenv := []string{}
+	for _, f := range imported.Dotenv {
+		dotenv = append(dotenv, abs(f))
+	}
+	imported.Dotenv = dotenv
+
+	if imported.HostsNode != nil {
+		hostImports := []string{}
+		for _, f := range imported.HostsNode.Imports {
+			hostImports = append(hostImports, abs(f))
+		}
+		imported.HostsNode.Imports = hostImports
+	}
+
+	if imported.Config != nil {
+		prependPaths := types.PrependPaths{}
+		for _, p := range imported.Config.PrependPaths {
+			p.Path = abs(p.Path)
+			prependPaths = append(prependPaths, p)
+		}
+		imported.Config.PrependPaths = prependPaths
+	}
+
+	return imported
+}
+
+// mergeTaskfile merges src into dst with the values of src taking
+// precedence. Env vars keep the order in which they were first set so
+// that the vars of an import can be used by the vars of the importer.
+func mergeTaskfile(dst, src *types.XTaskfile) {
+	if src.Tasks != nil {
+		maps.Copy(*dst.Tasks, *src.Tasks)
+	}
+
+	if src.Env != nil {
+		for k, v := range src.Env.Iter() {
+			dst.Env.Set(k, v)
+		}
+	}
+
+	for _, f := range src.Dotenv {
+		if !slices.Contains(dst.Dotenv, f) {
+			dst.Dotenv = append(dst.Dotenv, f)
+		}
+	}
+
+	if src.HostsNode != nil {
+		dst.HostsNode.Imports = append(dst.HostsNode.Imports, src.HostsNode.Imports...)
+		maps.Copy(dst.HostsNode.Hosts, src.HostsNode.Hosts)
+	}
+
+	maps.Copy(dst.Values, src.Values)
+
+	if src.Config != nil {
+		for k, v := range src.Config.Env.Iter() {
+			dst.Config.Env.Set(k, v)
+		}
+
+		dst.Config.PrependPaths = append(dst.Config.PrependPaths, src.Config.PrependPaths...)
+
+		ssh := src.Config.SSH
+		if ssh.KnownHosts != "" {
+			dst.Config.SSH.KnownHosts = ssh.KnownHosts
+		}
+		if ssh.HostKeyCheck != "" {
+			dst.Config.SSH.HostKeyCheck = ssh.HostKeyCheck
+		}
+		if ssh.Config != "" {
+			dst.Config.SSH.Config = ssh.Config
+		}
+		dst.Config.SSH.GatherFacts = dst.Config.SSH.GatherFacts || ssh.GatherFacts
+	}
+}
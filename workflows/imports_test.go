@@ -0,0 +1,85 @@
+package workflows
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveImportsNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XTASK_TEST_SHARED", filepath.Join(dir, "shared"))
+
+	writeFile(t, filepath.Join(dir, "shared", "ci.xtask.yaml"), `
+imports:
+  - uri: lint.xtask.yaml
+    namespace: lint
+env:
+  CI: "true"
+  REGISTRY: ghcr.io
+hosts:
+  - hosts.yaml
+tasks:
+  build:
+    run: go build ./...
+    needs: [lint:go, setup]
+  test:
+    run: go test ./...
+    needs: [build]
+`, 0644)
+
+	writeFile(t, filepath.Join(dir, "shared", "lint.xtask.yaml"), `
+tasks:
+  go:
+    run: go vet ./...
+`, 0644)
+
+	writeFile(t, filepath.Join(dir, "service", "xtaskfile"), `
+imports:
+  - uri: ${XTASK_TEST_SHARED}/ci.xtask.yaml
+    namespace: ci
+  - uri: ./missing.xtask.yaml
+    optional: true
+env:
+  REGISTRY: example.com
+tasks:
+  setup:
+    run: echo setup
+  ci:test:
+    run: echo overridden
+`, 0644)
+
+	tf := types.NewXTaskfile()
+	assert.NoError(t, tf.DecodeYAMLFile(filepath.Join(dir, "service", "xtaskfile")))
+	assert.NoError(t, resolveImports(tf, nil))
+	assert.Empty(t, tf.Imports)
+
+	tasks := *tf.Tasks
+	assert.Contains(t, tasks, "ci:lint:go")
+	assert.Equal(t, "ci:build", tasks["ci:build"].Id)
+	// needs of the import are namespaced, other needs refer to the importer.
+	assert.Equal(t, []string{"ci:lint:go", "setup"}, tasks["ci:build"].Needs.Names())
+	assert.Equal(t, "echo overridden", *tasks["ci:test"].Run)
+
+	assert.Equal(t, "true", tf.Env.GetString("CI"))
+	assert.Equal(t, "example.com", tf.Env.GetString("REGISTRY"))
+	assert.Equal(t, []string{filepath.Join(dir, "shared", "hosts.yaml")}, tf.HostsNode.Imports)
+}
+
+func TestResolveImportsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.xtask.yaml"), "imports: [b.xtask.yaml]\n", 0644)
+	writeFile(t, filepath.Join(dir, "b.xtask.yaml"), "imports: [a.xtask.yaml]\n", 0644)
+
+	tf := types.NewXTaskfile()
+	assert.NoError(t, tf.DecodeYAMLFile(filepath.Join(dir, "a.xtask.yaml")))
+	err := resolveImports(tf, nil)
+	assert.ErrorContains(t, err, "xtaskfile import cycle")
+
+	tf = types.NewXTaskfile()
+	tf.Path = filepath.Join(dir, "xtaskfile")
+	tf.Imports = types.Imports{{Uri: "missing.xtask.yaml"}}
+	assert.ErrorContains(t, resolveImports(tf, nil), "required xtaskfile import does not exist")
+}
@@ -3,7 +3,6 @@ package workflows
 import (
 	"errors"
 	"fmt"
-	"maps"
 	"net/url"
 	"os"
 	"os/user"
@@ -21,7 +20,16 @@ import (
 
 func (wf *Workflow) Load(taskfile types.XTaskfile) error {
 
-	err := wf.LoadEnv(taskfile)
+	remote, err := wf.remoteImports(taskfile.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := resolveImports(&taskfile, remote); err != nil {
+		return err
+	}
+
+	err = wf.LoadEnv(taskfile)
 	if err != nil {
 		return err
 	}
@@ -36,67 +44,20 @@ func (wf *Workflow) Load(taskfile types.XTaskfile) error {
 	hosts := map[string]types.Host{}
 	envMap := wf.Env
 	if len(taskfile.HostsNode.Imports) > 0 {
-		imports := taskfile.HostsNode.Imports
-		keys := envMap.Keys()
-		for _, imp := range imports {
-			opts := &env.ExpandOptions{
-				Get: func(key string) string {
-					s, ok := envMap.Get(key)
-					if ok {
-						return s
-					}
-
-					return ""
-				},
-				Set: func(key, value string) error {
-					envMap.Set(key, value)
-					return nil
-				},
-				Keys:                keys,
-				ExpandUnixArgs:      true,
-				ExpandWindowsVars:   false,
-				CommandSubstitution: taskfile.Config.Substitution,
-			}
-			next, err := env.ExpandWithOptions(imp, opts)
-			if err != nil {
-				return errors.New("failed to expand hosts import path: " + imp + " error: " + err.Error())
-			}
-
-			next = strings.TrimSpace(next)
-			optional := false
-			if strings.HasSuffix(next, "?") {
-				optional = true
-				next = strings.TrimSuffix(next, "?")
-			}
-
-			if !(filepath.IsAbs(next)) {
-				p, err := filepath.Abs(next)
-				if err != nil {
-					return errors.New("failed to get absolute path of hosts import: " + next + " error: " + err.Error())
-				}
-				next = p
-			}
-
-			if !isFile(next) {
-				if optional {
-					continue
-				} else {
-					return errors.New("required hosts import file does not exist: " + next)
-				}
-			}
-
-			data, err := os.ReadFile(next)
-			if err != nil {
-				return errors.New("failed to read hosts import file: " + next + " error: " + err.Error())
-			}
+		importer := &hostImporter{
+			env:          envMap,
+			substitution: taskfile.Config.Substitution,
+			loaded:       map[string]bool{},
+		}
 
-			var hostfile types.XHostFile
-			err = hostfile.Decode(data)
-			if err != nil {
-				return errors.New("failed to parse hosts import file: " + next + " error: " + err.Error())
-			}
+		// the imports are relative to the xtaskfile, the current dir.
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
 
-			maps.Copy(hosts, hostfile.Hosts)
+		if err := importer.importAll(taskfile.HostsNode.Imports, dir, hosts); err != nil {
+			return err
 		}
 	}
 
@@ -107,8 +68,44 @@ func (wf *Workflow) Load(taskfile types.XTaskfile) error {
 	}
 
 	wf.Hosts = hosts
+
+	taskDirs := taskfile.Config.Dirs.Tasks
+	if len(taskDirs) == 0 {
+		if dirs := envMap.GetString("XTASK_TASKS_DIRS"); dirs != "" {
+			taskDirs = strings.Split(dirs, string(os.PathListSeparator))
+		} else {
+			taskDirs = []string{"./.xtask/tasks"}
+			if data := envMap.GetString("XTASK_DATA_HOME"); data != "" {
+				taskDirs = append(taskDirs, filepath.Join(data, "tasks"))
+			}
+		}
+	}
+
 	keys := envMap.Keys()
 	for k, v := range *taskfile.Tasks {
+		if v.Uses != nil {
+			file, ok := findSharedTask(*v.Uses, rootDir, taskDirs)
+			if uses := strings.TrimSpace(*v.Uses); isRemoteImport(uses) {
+				file, _, err = remote.fetch(uses)
+				if err != nil {
+					return errors.New("failed to fetch shared task for task " + k + ": " + err.Error())
+				}
+				ok = true
+			}
+
+			if ok {
+				if !isFile(file) {
+					return errors.New("shared task file does not exist: " + file + " for task " + k)
+				}
+
+				shared, err := useSharedTask(v, file)
+				if err != nil {
+					return err
+				}
+				v = shared
+			}
+		}
+
 		wf.Tasks[k] = v
 
 		run := ""
@@ -127,12 +124,18 @@ func (wf *Workflow) Load(taskfile types.XTaskfile) error {
 						return errors.New("failed to parse task import URI: " + run + " error: " + err.Error())
 					}
 
-					if uri.Scheme != "file" {
+					switch {
+					case isRemoteImport(run):
+						file, _, err := remote.fetch(run)
+						if err != nil {
+							return errors.New("failed to fetch task import: " + run + " error: " + err.Error())
+						}
+						run = file
+					case uri.Scheme == "file":
+						run = strings.TrimSpace(uri.Path)
+					default:
 						continue
 					}
-
-					r := uri.Path
-					run = strings.TrimSpace(r)
 				}
 
 				opts := &env.ExpandOptions{
@@ -189,6 +192,12 @@ func (wf *Workflow) Load(taskfile types.XTaskfile) error {
 		}
 	}
 
+	if !wf.DryRun {
+		if err := remote.save(); err != nil {
+			return err
+		}
+	}
+
 	// continue loadding other parts like Hosts, Tasks, etc.
 
 	return nil
@@ -200,6 +209,23 @@ func (wf *Workflow) LoadEnv(taskfile types.XTaskfile) error {
 		return errors.New("taskfile path is empty")
 	}
 
+	if len(taskfile.Imports) > 0 {
+		remote, err := wf.remoteImports(taskfile.Path)
+		if err != nil {
+			return err
+		}
+
+		if err := resolveImports(&taskfile, remote); err != nil {
+			return err
+		}
+
+		if !wf.DryRun {
+			if err := remote.save(); err != nil {
+				return err
+			}
+		}
+	}
+
 	if !(filepath.IsAbs(taskfile.Path)) {
 		p, err := filepath.Abs(taskfile.Path)
 		if err != nil {
@@ -221,6 +247,11 @@ func (wf *Workflow) LoadEnv(taskfile types.XTaskfile) error {
 		wf = NewWorkflow()
 	}
 
+	// command substitution runs commands, which a dry run must not do.
+	if wf.DryRun && taskfile.Config != nil {
+		taskfile.Config.Substitution = false
+	}
+
 	envMap := types.NewEnv()
 	for _, n := range os.Environ() {
 		parts := strings.SplitN(n, "=", 2)
@@ -242,6 +273,27 @@ func (wf *Workflow) LoadEnv(taskfile types.XTaskfile) error {
 	envMap.Set("XTASK_CONTEXT", wf.ContextName)
 	envMap.Set("XTASK_SHELL", taskfile.Config.Shell)
 
+	// the process env takes precedence so that ssh settings can be
+	// changed without editing the xtaskfile.
+	if taskfile.Config.SSH.KnownHosts != "" && !envMap.Has("XTASK_SSH_KNOWN_HOSTS") {
+		envMap.Set("XTASK_SSH_KNOWN_HOSTS", taskfile.Config.SSH.KnownHosts)
+	}
+
+	// insecure is only accepted per host.
+	if taskfile.Config.SSH.HostKeyCheck == "insecure" {
+		return errors.New("config.ssh.host-key-check may not be insecure, set host-key-check on the hosts that need it")
+	}
+
+	if taskfile.Config.SSH.HostKeyCheck != "" && !envMap.Has("XTASK_SSH_HOST_KEY_CHECK") {
+		envMap.Set("XTASK_SSH_HOST_KEY_CHECK", taskfile.Config.SSH.HostKeyCheck)
+	}
+
+	if taskfile.Config.SSH.Config != "" && !envMap.Has("XTASK_SSH_CONFIG") {
+		envMap.Set("XTASK_SSH_CONFIG", taskfile.Config.SSH.Config)
+	}
+
+	wf.Config.SSH = taskfile.Config.SSH
+
 	if wf.Config.Dirs.Scripts == "" {
 		wf.Config.Dirs.Scripts = "./.xtask/scripts"
 	}
@@ -686,3 +738,24 @@ func normalizeEnv(envMap *types.Env) error {
 
 	return nil
 }
+
+// remoteImports returns the fetcher of the remote imports of the xtaskfile
+// at path, which pins them in the lock file next to it.
+func (wf *Workflow) remoteImports(path string) (*remoteImports, error) {
+	if wf.remote != nil {
+		return wf.remote, nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := newRemoteImports(filepath.Dir(path), wf.UpdateImports, wf.OfflineImports)
+	if err != nil {
+		return nil, err
+	}
+
+	wf.remote = remote
+	return remote, nil
+}
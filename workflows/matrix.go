@@ -0,0 +1,192 @@
+package workflows
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// matrixCombinations returns the combinations of the values of m, ordered
+// by the sorted keys. Exclude is applied before include.
+func matrixCombinations(m *types.Matrix) []map[string]string {
+	keys := slices.Sorted(maps.Keys(m.Values))
+	combinations := []map[string]string{}
+	if len(keys) > 0 {
+		combinations = append(combinations, map[string]string{})
+	}
+
+	for _, key := range keys {
+		next := []map[string]string{}
+		for _, combination := range combinations {
+			for _, value := range m.Values[key] {
+				c := maps.Clone(combination)
+				c[key] = value
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	combinations = slices.DeleteFunc(combinations, func(c map[string]string) bool {
+		for _, exclude := range m.Exclude {
+			if matrixMatches(c, exclude, nil) {
+				return true
+			}
+		}
+		return false
+	})
+
+	// an include only extends the combinations that match its values of
+	// the matrix keys, it adds a combination when it matches none.
+	for _, include := range m.Include {
+		matched := false
+		for _, c := range combinations {
+			if matrixMatches(c, include, m.Values) {
+				matched = true
+				for k, v := range include {
+					c[k] = v
+				}
+			}
+		}
+
+		if !matched {
+			combinations = append(combinations, maps.Clone(include))
+		}
+	}
+
+	return combinations
+}
+
+// matrixMatches reports whether combination has the values of entry. When
+// keys is set, only the values of entry for those keys are compared.
+func matrixMatches(combination map[string]string, entry map[string]string, keys map[string][]string) bool {
+	for k, v := range entry {
+		if keys != nil {
+			if _, ok := keys[k]; !ok {
+				continue
+			}
+		}
+
+		if combination[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matrixTaskId returns the id of a combination of a task, e.g.
+// test[go=1.22,os=linux]. Values added by include to a combination are
+// not part of the id.
+func matrixTaskId(id string, values map[string]string, keys map[string][]string) string {
+	pairs := []string{}
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		if _, ok := keys[k]; ok {
+			pairs = append(pairs, k+"="+values[k])
+		}
+	}
+
+	return id + "[" + strings.Join(pairs, ",") + "]"
+}
+
+// expandMatrix returns the tasks with a task for each combination of the
+// tasks with a matrix, and the values of each combination by task id. A
+// task with a matrix only needs its combinations, which run in parallel.
+func expandMatrix(all types.Tasks) (types.Tasks, map[string]map[string]string, error) {
+	expanded := types.Tasks{}
+	values := map[string]map[string]string{}
+	for id, task := range all {
+		if task.Matrix == nil {
+			expanded[id] = task
+			continue
+		}
+
+		combinations := matrixCombinations(task.Matrix)
+		if len(combinations) == 0 {
+			return nil, nil, errors.New("matrix of task " + id + " has no combinations")
+		}
+
+		group := types.Task{
+			Id:     id,
+			Name:   task.Name,
+			Desc:   task.Desc,
+			Help:   task.Help,
+			Params: task.Params,
+			Needs:  types.Needs{},
+		}
+
+		for _, combination := range combinations {
+			subId := matrixTaskId(id, combination, task.Matrix.Values)
+			if _, ok := all[subId]; ok {
+				return nil, nil, errors.New("matrix of task " + id + " conflicts with task " + subId)
+			}
+
+			if subId == id+"[]" {
+				return nil, nil, errors.New("matrix of task " + id + " has an include without any matrix key that matches no combination")
+			}
+
+			// an include that matches a combination is merged into it, so a
+			// repeated combination comes from a repeated value.
+			if _, ok := values[subId]; ok {
+				return nil, nil, errors.New("matrix of task " + id + " repeats the combination " + subId)
+			}
+
+			sub := task
+			sub.Id = subId
+			sub.Matrix = nil
+			if task.Name != nil && len(*task.Name) > 0 {
+				name := *task.Name + strings.TrimPrefix(subId, id)
+				sub.Name = &name
+			}
+
+			expanded[subId] = sub
+			values[subId] = combination
+			group.Needs = append(group.Needs, types.Need{Name: subId, Parallel: true})
+		}
+
+		expanded[id] = group
+	}
+
+	return expanded, values, nil
+}
+
+// printMatrixReport writes the status of each combination of the tasks
+// with a matrix that were part of the run.
+func (ws *Workflow) printMatrixReport(graph *taskGraph, results map[string]*tasks.TaskResult) {
+	for _, node := range graph.nodes {
+		task, ok := ws.Tasks[node.task.Id]
+		if !ok || task.Matrix == nil {
+			continue
+		}
+
+		os.Stdout.WriteString("\x1b[1m" + task.Id + "\x1b[22m matrix\n")
+		for _, need := range node.task.Needs {
+			os.Stdout.WriteString("  " + need.Name + ": " + statusName(results[need.Name]) + "\n")
+		}
+	}
+}
+
+func statusName(result *tasks.TaskResult) string {
+	if result == nil {
+		return "not run"
+	}
+
+	switch result.Status {
+	case statuses.Ok:
+		return "ok"
+	case statuses.Error:
+		return "failed"
+	case statuses.Skipped:
+		return "skipped"
+	case statuses.Cancelled:
+		return "cancelled"
+	}
+
+	return "not run"
+}
@@ -0,0 +1,102 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	tf := types.NewXTaskfile()
+	err := yaml.Unmarshal([]byte(`
+tasks:
+  test:
+    needs: [build]
+    matrix:
+      os: [linux, windows]
+      go: ["1.22", 1.23]
+      exclude:
+        - os: windows
+          go: "1.22"
+      include:
+        - os: linux
+          experimental: true
+        - os: darwin
+          go: "1.23"
+    run: go test ./...
+  build: go build ./...
+`), tf)
+	assert.NoError(t, err)
+
+	expanded, values, err := expandMatrix(*tf.Tasks)
+	assert.NoError(t, err)
+
+	ids := []string{
+		"test[go=1.22,os=linux]",
+		"test[go=1.23,os=linux]",
+		"test[go=1.23,os=windows]",
+		"test[go=1.23,os=darwin]",
+	}
+	assert.Equal(t, ids, expanded["test"].Needs.Names())
+	assert.Nil(t, expanded["test"].Run)
+	assert.Contains(t, expanded, "build")
+	assert.NotContains(t, expanded, "test[go=1.22,os=windows]")
+
+	sub := expanded["test[go=1.22,os=linux]"]
+	assert.Equal(t, []string{"build"}, sub.Needs.Names())
+	assert.Equal(t, "go test ./...", *sub.Run)
+	assert.Nil(t, sub.Matrix)
+	assert.Equal(t, map[string]string{"os": "linux", "go": "1.22", "experimental": "true"}, values["test[go=1.22,os=linux]"])
+	assert.Equal(t, map[string]string{"os": "windows", "go": "1.23"}, values["test[go=1.23,os=windows]"])
+
+	graph, err := buildTaskGraph([]string{"test"}, expanded)
+	assert.NoError(t, err)
+	assert.Len(t, graph.nodes, 6)
+
+	// the combinations only need build, so they may run at the same time.
+	for _, id := range ids {
+		node := graph.index[id]
+		assert.Len(t, node.needs, 1, id)
+	}
+
+	_, _, err = expandMatrix(types.Tasks{
+		"test":           {Id: "test", Matrix: &types.Matrix{Values: map[string][]string{"os": {"linux"}}}},
+		"test[os=linux]": {Id: "test[os=linux]"},
+	})
+	assert.ErrorContains(t, err, "conflicts with task test[os=linux]")
+
+	_, _, err = expandMatrix(types.Tasks{
+		"test": {Id: "test", Matrix: &types.Matrix{Values: map[string][]string{"os": {}}}},
+	})
+	assert.ErrorContains(t, err, "has no combinations")
+
+	// an include that repeats a combination extends it.
+	expanded, values, err = expandMatrix(types.Tasks{
+		"test": {Id: "test", Matrix: &types.Matrix{
+			Values: map[string][]string{"os": {"linux"}},
+			Include: []map[string]string{
+				{"os": "darwin"},
+				{"os": "darwin", "experimental": "true"},
+			},
+		}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test[os=linux]", "test[os=darwin]"}, expanded["test"].Needs.Names())
+	assert.Equal(t, map[string]string{"os": "darwin", "experimental": "true"}, values["test[os=darwin]"])
+
+	_, _, err = expandMatrix(types.Tasks{
+		"test": {Id: "test", Matrix: &types.Matrix{Values: map[string][]string{"os": {"linux", "linux"}}}},
+	})
+	assert.ErrorContains(t, err, "repeats the combination test[os=linux]")
+
+	_, _, err = expandMatrix(types.Tasks{
+		"test": {Id: "test", Matrix: &types.Matrix{
+			Values:  map[string][]string{"os": {"linux"}},
+			Exclude: []map[string]string{{"os": "linux"}},
+			Include: []map[string]string{{"experimental": "true"}},
+		}},
+	})
+	assert.ErrorContains(t, err, "include without any matrix key")
+}
@@ -0,0 +1,213 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyprxlabs/go/dotenv"
+	"github.com/hyprxlabs/xtask/statuses"
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+var expressionPattern = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+
+// readOutputFile reads the dotenv formatted file written by a task to the
+// path in XTASK_OUTPUT. Values of declared outputs are converted to the
+// declared type and declared outputs that were not written are set to
+// their default value.
+func readOutputFile(outputFile string, declared types.Outputs) (map[string]interface{}, error) {
+	outputs := map[string]interface{}{}
+
+	bytes, err := os.ReadFile(outputFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New("Failed to read XTASK_OUTPUT file: " + err.Error())
+	}
+
+	if len(bytes) > 0 {
+		doc, err := dotenv.Parse(string(bytes))
+		if err != nil {
+			return nil, errors.New("Failed to parse XTASK_OUTPUT file: " + err.Error())
+		}
+
+		for _, node := range doc.ToArray() {
+			if node.Type != dotenv.VARIABLE_TOKEN || node.Key == nil {
+				continue
+			}
+
+			key := *node.Key
+			typ := ""
+			if output, ok := declared.Get(key); ok && output.Type != nil {
+				typ = *output.Type
+			}
+
+			value, err := convertOutput(node.Value, typ)
+			if err != nil {
+				return nil, errors.New("invalid value for output " + key + ": " + err.Error())
+			}
+
+			outputs[key] = value
+		}
+	}
+
+	for _, output := range declared {
+		if _, ok := outputs[output.Id]; ok || output.Default == nil {
+			continue
+		}
+
+		typ := ""
+		if output.Type != nil {
+			typ = *output.Type
+		}
+
+		value, err := convertOutput(*output.Default, typ)
+		if err != nil {
+			return nil, errors.New("invalid default for output " + output.Id + ": " + err.Error())
+		}
+
+		outputs[output.Id] = value
+	}
+
+	return outputs, nil
+}
+
+func convertOutput(value string, typ string) (interface{}, error) {
+	switch strings.ToLower(typ) {
+	case "", "string":
+		return value, nil
+	case "number", "int", "integer", "float":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i, nil
+		}
+
+		return strconv.ParseFloat(value, 64)
+	case "bool", "boolean":
+		return strconv.ParseBool(value)
+	case "json", "object", "array":
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, errors.New("unknown output type " + typ)
+	}
+}
+
+// expandOutputs replaces `${{ tasks.<id>.outputs.<name> }}` expressions
+// with the outputs of tasks that have already run. Other expressions are
+// left as they are.
+func expandOutputs(s string, results map[string]*tasks.TaskResult) (string, error) {
+	if !strings.Contains(s, "${{") {
+		return s, nil
+	}
+
+	var expandErr error
+	out := expressionPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if expandErr != nil {
+			return match
+		}
+
+		expr := expressionPattern.FindStringSubmatch(match)[1]
+		if !strings.HasPrefix(expr, "tasks.") {
+			return match
+		}
+
+		// the id of a task may contain dots, so the last .outputs. separates
+		// the id from the name of the output.
+		ref := strings.TrimPrefix(expr, "tasks.")
+		i := strings.LastIndex(ref, ".outputs.")
+		if i < 1 {
+			expandErr = errors.New("invalid output expression: " + expr)
+			return match
+		}
+
+		id := ref[:i]
+		name := ref[i+len(".outputs."):]
+
+		result, ok := results[id]
+		if !ok || result.Status == statuses.None {
+			expandErr = errors.New("task " + id + " has not run; add it to needs to use its outputs")
+			return match
+		}
+
+		value, ok := result.Output[name]
+		if !ok {
+			if result.Status == statuses.Skipped {
+				return ""
+			}
+
+			expandErr = errors.New("task " + id + " has no output " + name)
+			return match
+		}
+
+		return formatOutput(value)
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return out, nil
+}
+
+// expandOutputsIn returns a copy of value with the output expressions of
+// all strings, including the ones in nested lists and maps, replaced.
+func expandOutputsIn(value interface{}, results map[string]*tasks.TaskResult) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return expandOutputs(v, results)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			e, err := expandOutputsIn(item, results)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = e
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			e, err := expandOutputsIn(item, results)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = e
+		}
+		return m, nil
+	default:
+		return value, nil
+	}
+}
+
+func formatOutput(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	return string(bytes)
+}
+
+// outputData returns the outputs of the tasks in results for use in
+// templates, e.g. `{{ .tasks.build.outputs.version }}`.
+func outputData(results map[string]*tasks.TaskResult) map[string]interface{} {
+	data := map[string]interface{}{}
+	for id, result := range results {
+		data[id] = map[string]interface{}{
+			"outputs": result.Output,
+		}
+	}
+
+	return data
+}
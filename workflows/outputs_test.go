@@ -0,0 +1,56 @@
+package workflows
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOutputFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "output")
+	content := "version=1.2.3\nmeta='{\"a\":[1,2]}'\ncount=4\nok=true\n"
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+	typ := func(s string) *string { return &s }
+	declared := types.Outputs{
+		{Id: "meta", Type: typ("json")},
+		{Id: "count", Type: typ("number")},
+		{Id: "ok", Type: typ("bool")},
+		{Id: "missing", Default: typ("none")},
+	}
+
+	outputs, err := readOutputFile(file, declared)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", outputs["version"])
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{float64(1), float64(2)}}, outputs["meta"])
+	assert.Equal(t, int64(4), outputs["count"])
+	assert.Equal(t, true, outputs["ok"])
+	assert.Equal(t, "none", outputs["missing"])
+}
+
+func TestExpandOutputs(t *testing.T) {
+	build := tasks.NewTaskResult().Ok()
+	build.Output["version"] = "1.2.3"
+	build.Output["count"] = int64(4)
+	results := map[string]*tasks.TaskResult{"app.build": build}
+
+	out, err := expandOutputs("v${{ tasks.app.build.outputs.version }}-${{tasks.app.build.outputs.count}} ${{ matrix.os }}", results)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.2.3-4 ${{ matrix.os }}", out)
+
+	_, err = expandOutputs("${{ tasks.app.build.outputs.missing }}", results)
+	assert.Error(t, err)
+
+	_, err = expandOutputs("${{ tasks.test.outputs.version }}", results)
+	assert.Error(t, err)
+
+	with, err := expandOutputsIn(map[string]interface{}{
+		"files": []interface{}{"dist/${{ tasks.app.build.outputs.version }}.zip"},
+	}, results)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"files": []interface{}{"dist/1.2.3.zip"}}, with)
+}
@@ -0,0 +1,198 @@
+package workflows
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/spf13/pflag"
+)
+
+// taskParams holds the values of the params of a task run from the command
+// line and the args that are passed to the task.
+type taskParams struct {
+	values map[string]interface{}
+	args   []string
+}
+
+// paramValue is the value of a param flag, its type is shown in the usage.
+type paramValue struct {
+	value string
+	typ   string
+}
+
+func (v *paramValue) String() string {
+	return v.value
+}
+
+func (v *paramValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *paramValue) Type() string {
+	return v.typ
+}
+
+// paramFlags returns the flags of the params of task. The values of all
+// but boolean params are read as strings and converted by parseParams.
+func paramFlags(task types.Task) (*pflag.FlagSet, error) {
+	flags := pflag.NewFlagSet(task.Id, pflag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+
+	for _, param := range task.Params {
+		name := inputId(param.Input)
+		if name == "" {
+			return nil, errors.New("task " + task.Id + " has a param without an id")
+		}
+
+		if flags.Lookup(name) != nil {
+			return nil, errors.New("task " + task.Id + " declares param " + name + " twice")
+		}
+
+		if param.Type != nil && *param.Type != "" && !inputTypes[*param.Type] {
+			return nil, errors.New("task " + task.Id + ": param " + name + " has unknown type " + *param.Type)
+		}
+
+		short := ""
+		if param.Short != nil {
+			short = strings.TrimPrefix(*param.Short, "-")
+			if len(short) != 1 {
+				return nil, errors.New("task " + task.Id + ": the short flag of param " + name + " must be one letter")
+			}
+
+			if flags.ShorthandLookup(short) != nil {
+				return nil, errors.New("task " + task.Id + ": params use the short flag -" + short + " twice")
+			}
+		}
+
+		usage := ""
+		if param.Help != nil {
+			usage = *param.Help
+		} else if param.Desc != nil {
+			usage = *param.Desc
+		}
+
+		if len(param.Enum) > 0 {
+			usage = strings.TrimSpace(usage + " (one of " + strings.Join(param.Enum, ", ") + ")")
+		}
+
+		if param.Required != nil && *param.Required {
+			usage = strings.TrimSpace(usage + " (required)")
+		}
+
+		def := ""
+		if param.Default != nil {
+			def = *param.Default
+		}
+
+		if param.Type != nil && *param.Type == "boolean" {
+			value := false
+			if def != "" {
+				b, err := strconv.ParseBool(def)
+				if err != nil {
+					return nil, errors.New("task " + task.Id + ": param " + name + " has an invalid default " + def)
+				}
+				value = b
+			}
+
+			flags.BoolP(name, short, value, usage)
+			continue
+		}
+
+		typ := "string"
+		if param.Type != nil && *param.Type != "" {
+			typ = *param.Type
+		}
+
+		flags.VarP(&paramValue{value: def, typ: typ}, name, short, usage)
+	}
+
+	return flags, nil
+}
+
+// parseParams parses the command line args of task into the values of its
+// params. The args that are not flags are passed to the task. A task
+// without params gets all args.
+func parseParams(task types.Task, args []string) (*taskParams, error) {
+	params := &taskParams{values: map[string]interface{}{}, args: args}
+	if len(task.Params) == 0 {
+		return params, nil
+	}
+
+	flags, err := paramFlags(task)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, errors.New("invalid args for task " + task.Id + ": " + err.Error())
+	}
+
+	for _, param := range task.Params {
+		name := inputId(param.Input)
+		if param.Type != nil && *param.Type == "boolean" {
+			params.values[name], _ = flags.GetBool(name)
+			continue
+		}
+
+		value := flags.Lookup(name).Value.String()
+		if !flags.Changed(name) && param.Default == nil {
+			if param.Required != nil && *param.Required {
+				return nil, errors.New("task " + task.Id + " requires --" + name)
+			}
+
+			params.values[name] = ""
+			continue
+		}
+
+		if len(param.Enum) > 0 && !slices.Contains(param.Enum, value) {
+			return nil, errors.New("invalid value " + value + " for --" + name + " of task " + task.Id + ", expected one of " + strings.Join(param.Enum, ", "))
+		}
+
+		converted, err := inputValue(param.Input, value)
+		if err != nil {
+			return nil, errors.New("invalid --" + name + " for task " + task.Id + ": " + err.Error())
+		}
+		params.values[name] = converted
+	}
+
+	params.args = flags.Args()
+	return params, nil
+}
+
+// TaskHelp returns the usage of a task with params when args ask for help
+// with --help or -h, unless the task uses -h for one of its params.
+func (ws *Workflow) TaskHelp(id string, args []string) (string, bool) {
+	task, ok := ws.Tasks[id]
+	if !ok || len(task.Params) == 0 {
+		return "", false
+	}
+
+	flags, err := paramFlags(task)
+	if err != nil {
+		return "", false
+	}
+
+	if err := flags.Parse(args); !errors.Is(err, pflag.ErrHelp) {
+		return "", false
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("Usage: xtask run " + id + " [flags] [args...]\n")
+	if task.Desc != nil && *task.Desc != "" {
+		sb.WriteString("\n" + *task.Desc + "\n")
+	}
+
+	if task.Help != nil && *task.Help != "" {
+		sb.WriteString("\n" + strings.TrimSpace(*task.Help) + "\n")
+	}
+
+	sb.WriteString("\nFlags:\n")
+	sb.WriteString(flags.FlagUsages())
+	return sb.String(), true
+}
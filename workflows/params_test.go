@@ -0,0 +1,59 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseParams(t *testing.T) {
+	required := true
+	str := func(s string) *string { return &s }
+	task := types.Task{
+		Id: "deploy",
+		Params: []types.Param{
+			{Input: types.Input{Id: "tag", Required: &required}, Short: str("t")},
+			{Input: types.Input{Id: "env", Default: str("dev")}, Enum: []string{"dev", "prod"}},
+			{Input: types.Input{Id: "count", Type: str("integer"), Default: str("2")}},
+			{Input: types.Input{Id: "dry-run", Type: str("boolean")}},
+		},
+	}
+
+	params, err := parseParams(task, []string{"-t", "v1.0.0", "--env", "prod", "--dry-run", "extra"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"tag":     "v1.0.0",
+		"env":     "prod",
+		"count":   2,
+		"dry-run": true,
+	}, params.values)
+	assert.Equal(t, []string{"extra"}, params.args)
+
+	_, err = parseParams(task, []string{"--env", "prod"})
+	assert.ErrorContains(t, err, "task deploy requires --tag")
+
+	_, err = parseParams(task, []string{"--tag", "v1", "--env", "qa"})
+	assert.ErrorContains(t, err, "expected one of dev, prod")
+
+	_, err = parseParams(task, []string{"--tag", "v1", "--count", "many"})
+	assert.ErrorContains(t, err, "invalid --count")
+
+	_, err = parseParams(task, []string{"--tag", "v1", "--unknown"})
+	assert.ErrorContains(t, err, "invalid args for task deploy")
+
+	// a task without params gets all args.
+	params, err = parseParams(types.Task{Id: "echo"}, []string{"--tag", "v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--tag", "v1"}, params.args)
+
+	ws := NewWorkflow()
+	ws.Tasks["deploy"] = task
+	help, ok := ws.TaskHelp("deploy", []string{"--help"})
+	assert.True(t, ok)
+	assert.Contains(t, help, "-t, --tag string")
+	assert.Contains(t, help, "(one of dev, prod)")
+
+	_, ok = ws.TaskHelp("deploy", []string{"--tag", "v1"})
+	assert.False(t, ok)
+}
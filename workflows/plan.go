@@ -0,0 +1,115 @@
+package workflows
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// planStep describes a task as it would run for --dry-run.
+type planStep struct {
+	task      types.Task
+	name      string
+	data      *tasks.TaskData
+	predicate bool
+	upToDate  bool
+	// groupOnly is set for tasks without run or uses.
+	groupOnly bool
+}
+
+// printPlan writes the resolved settings of a task instead of running it.
+func (ws *Workflow) printPlan(state *runState, step planStep) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.step++
+	sb := &strings.Builder{}
+	sb.WriteString(strconv.Itoa(state.step) + ". \x1b[1m" + step.name + "\x1b[22m")
+	if step.name != step.task.Id {
+		sb.WriteString(" (" + step.task.Id + ")")
+	}
+	sb.WriteString("\n")
+
+	field := func(name string, value string) {
+		sb.WriteString("     " + name + ":" + strings.Repeat(" ", 9-len(name)) + value + "\n")
+	}
+
+	if len(step.task.Needs) > 0 {
+		field("needs", strings.Join(step.task.Needs.Names(), ", "))
+	}
+
+	if step.groupOnly {
+		field("runs", "nothing, only groups its needs")
+		os.Stdout.WriteString(sb.String())
+		return
+	}
+
+	data := step.data
+	switch runner := tasks.RunnerName(data.Uses); runner {
+	case "":
+		if path, ok := tasks.FindPlugin(data.Uses, &data.Env); ok {
+			field("uses", data.Uses+" (plugin "+path+")")
+		} else {
+			field("uses", data.Uses+" (unsupported)")
+		}
+	case data.Uses:
+		field("uses", data.Uses)
+	default:
+		field("uses", data.Uses+" ("+runner+" runner)")
+	}
+	field("cwd", data.Cwd)
+	if data.Timeout > 0 {
+		field("timeout", data.Timeout.String())
+	}
+
+	if step.task.Retries > 0 {
+		field("retries", strconv.Itoa(step.task.Retries))
+	}
+
+	if step.task.Predicate != nil && len(*step.task.Predicate) > 0 {
+		field("if", strconv.FormatBool(step.predicate))
+	}
+
+	if len(step.task.Hosts) > 0 {
+		aliases := []string{}
+		for alias := range data.Hosts {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+
+		hosts := []string{}
+		for _, alias := range aliases {
+			host := data.Hosts[alias]
+			if host.Host != alias {
+				alias += " (" + host.Host + ")"
+			}
+			hosts = append(hosts, alias)
+		}
+
+		selector := strings.Join(step.task.Hosts, ", ")
+		if ws.Limit != "" {
+			selector += " limited to " + ws.Limit
+		}
+
+		if len(hosts) == 0 {
+			field("hosts", selector+" (no hosts match)")
+		} else {
+			field("hosts", selector+" -> "+strings.Join(hosts, ", "))
+		}
+	}
+
+	switch {
+	case !step.predicate:
+		field("status", "skipped, if is false")
+	case step.upToDate:
+		field("status", "skipped, up to date")
+	default:
+		field("status", "would run")
+	}
+
+	os.Stdout.WriteString(sb.String())
+}
@@ -0,0 +1,426 @@
+package workflows
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/hyprxlabs/xtask/paths"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	exec.Register("git", &exec.Executable{
+		Name:     "git",
+		Variable: "XTASK_GIT_EXE",
+		Linux:    []string{"git"},
+		Windows:  []string{"git"},
+	})
+}
+
+// LockFileName is the file next to the xtaskfile that pins the content of
+// remote imports.
+const LockFileName = "xtask.lock"
+
+type lockEntry struct {
+	Sha256 string `yaml:"sha256"`
+	Commit string `yaml:"commit,omitempty"`
+}
+
+type importLock struct {
+	Imports map[string]lockEntry `yaml:"imports"`
+}
+
+// remoteImports downloads remote xtaskfiles and shared tasks into the
+// cache and pins them in the lock file. Sources are:
+//
+//	https://example.com/ci.xtask.yaml
+//	git+https://example.com/org/lib.git//tasks/build.xtask.yaml@v1.2.0
+//	git+file:///srv/git/lib.git//ci.xtask.yaml
+//
+// A git source without a ref uses the default branch of the repository.
+type remoteImports struct {
+	cacheDir string
+	lockPath string
+	lock     importLock
+	// update downloads imports again and replaces their lock entries.
+	update bool
+	// offline fails instead of downloading an import.
+	offline bool
+	used    map[string]bool
+	changed bool
+}
+
+func newRemoteImports(rootDir string, update bool, offline bool) (*remoteImports, error) {
+	cacheHome := os.Getenv("XTASK_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome, _ = paths.UserCacheDir()
+	}
+
+	ri := &remoteImports{
+		cacheDir: filepath.Join(cacheHome, "imports"),
+		lockPath: filepath.Join(rootDir, LockFileName),
+		lock:     importLock{Imports: map[string]lockEntry{}},
+		update:   update,
+		offline:  offline,
+		used:     map[string]bool{},
+	}
+
+	data, err := os.ReadFile(ri.lockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ri, nil
+		}
+		return nil, errors.New("failed to read " + ri.lockPath + ": " + err.Error())
+	}
+
+	if err := yaml.Unmarshal(data, &ri.lock); err != nil {
+		return nil, errors.New("failed to parse " + ri.lockPath + ": " + err.Error())
+	}
+
+	if ri.lock.Imports == nil {
+		ri.lock.Imports = map[string]lockEntry{}
+	}
+
+	return ri, nil
+}
+
+// isRemoteImport reports whether uri is downloaded instead of read.
+func isRemoteImport(uri string) bool {
+	return strings.HasPrefix(uri, "https://") || strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "git+")
+}
+
+// checkInsecureImport rejects imports over plain http, which may be changed
+// in transit, unless the server is on the loopback interface.
+func checkInsecureImport(uri string) error {
+	if !strings.HasPrefix(strings.TrimPrefix(uri, "git+"), "http://") {
+		return nil
+	}
+
+	u, err := url.Parse(strings.TrimPrefix(uri, "git+"))
+	if err != nil {
+		return errors.New("invalid remote import " + uri + ": " + err.Error())
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return errors.New("remote import " + uri + " must use https, http is only allowed for localhost")
+}
+
+// fetch returns the cached file of a remote import and its base, which
+// relative imports of the file are resolved from.
+func (ri *remoteImports) fetch(uri string) (string, string, error) {
+	if err := checkInsecureImport(uri); err != nil {
+		return "", "", err
+	}
+
+	ri.used[uri] = true
+	if strings.HasPrefix(uri, "git+") {
+		return ri.fetchGit(uri)
+	}
+
+	file, err := ri.fetchHTTP(uri)
+	if err != nil {
+		return "", "", err
+	}
+
+	u, _ := url.Parse(uri)
+	u.Path = path.Dir(u.Path) + "/"
+	u.RawQuery = ""
+	return file, u.String(), nil
+}
+
+func (ri *remoteImports) fetchHTTP(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", errors.New("invalid remote import " + uri + ": " + err.Error())
+	}
+
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = "xtaskfile"
+	}
+
+	file := filepath.Join(ri.cacheDir, cacheKey(uri), name)
+	entry, locked := ri.lock.Imports[uri]
+	if !ri.update && isFile(file) {
+		sum, err := fileSha256(file)
+		if err != nil {
+			return "", err
+		}
+
+		if !locked {
+			if ri.offline {
+				return "", errors.New("remote import " + uri + " is not in " + LockFileName + "; run xtask imports update")
+			}
+
+			ri.pin(uri, lockEntry{Sha256: sum})
+			return file, nil
+		}
+
+		if sum == entry.Sha256 {
+			return file, nil
+		}
+	}
+
+	if ri.offline {
+		if !locked {
+			return "", errors.New("remote import " + uri + " is not in " + LockFileName + "; run xtask imports update")
+		}
+		return "", errors.New("remote import " + uri + " is not cached or does not match " + LockFileName)
+	}
+
+	client := &http.Client{
+		Timeout: 60 * time.Second,
+		// a redirect must not downgrade the import to plain http.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			return checkInsecureImport(req.URL.String())
+		},
+	}
+	resp, err := client.Get(uri)
+	if err != nil {
+		return "", errors.New("failed to download " + uri + ": " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("failed to download " + uri + ": " + resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.New("failed to download " + uri + ": " + err.Error())
+	}
+
+	sum := sha256Hex(data)
+	if locked && !ri.update && sum != entry.Sha256 {
+		return "", errors.New("remote import " + uri + " does not match " + LockFileName + ": expected sha256 " + entry.Sha256 + ", got " + sum + "; run xtask imports update if the change is expected")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return "", errors.New("failed to cache " + uri + ": " + err.Error())
+	}
+
+	ri.pin(uri, lockEntry{Sha256: sum})
+	return file, nil
+}
+
+// parseGitImport splits a git source into the repository, the path of the
+// file in the repository and the ref.
+func parseGitImport(uri string) (repo string, file string, ref string, err error) {
+	rest := strings.TrimPrefix(uri, "git+")
+	scheme := strings.Index(rest, "://")
+	if scheme < 0 {
+		return "", "", "", errors.New("invalid git import " + uri + ", expected git+<url>//<path>[@ref]")
+	}
+
+	sep := strings.Index(rest[scheme+3:], "//")
+	if sep < 0 {
+		return "", "", "", errors.New("invalid git import " + uri + ", expected git+<url>//<path>[@ref]")
+	}
+
+	repo = rest[:scheme+3+sep]
+	file = rest[scheme+3+sep+2:]
+	if i := strings.LastIndex(file, "@"); i > -1 {
+		ref = file[i+1:]
+		file = file[:i]
+	}
+
+	if file == "" {
+		return "", "", "", errors.New("invalid git import " + uri + ", the path of the file is empty")
+	}
+
+	return repo, file, ref, nil
+}
+
+// fetchGit checks out the ref of a git source, or the commit pinned in the
+// lock file, into the cache. The base is the checkout so relative imports
+// of the file come from the same commit.
+func (ri *remoteImports) fetchGit(uri string) (string, string, error) {
+	repo, name, ref, err := parseGitImport(uri)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir := filepath.Join(ri.cacheDir, "git", cacheKey(repo+"@"+ref))
+	file := filepath.Join(dir, filepath.FromSlash(name))
+	entry, locked := ri.lock.Imports[uri]
+
+	if !ri.update && locked && isDir(filepath.Join(dir, ".git")) {
+		if head, err := git(dir, "rev-parse", "HEAD"); err == nil && head == entry.Commit {
+			if sum, err := fileSha256(file); err == nil && sum == entry.Sha256 {
+				return file, dir, nil
+			}
+		}
+	}
+
+	if ri.offline {
+		if !locked {
+			return "", "", errors.New("remote import " + uri + " is not in " + LockFileName + "; run xtask imports update")
+		}
+		return "", "", errors.New("remote import " + uri + " is not cached or does not match " + LockFileName)
+	}
+
+	if !isDir(filepath.Join(dir, ".git")) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", "", err
+		}
+
+		if _, err := git(dir, "init", "--quiet"); err != nil {
+			return "", "", err
+		}
+
+		if _, err := git(dir, "remote", "add", "origin", repo); err != nil {
+			return "", "", err
+		}
+	}
+
+	commit := ""
+	if locked && !ri.update {
+		commit = entry.Commit
+		if _, err := git(dir, "cat-file", "-e", commit+"^{commit}"); err != nil {
+			if _, err := git(dir, "fetch", "--quiet", "--tags", "origin"); err != nil {
+				return "", "", errors.New("failed to fetch " + repo + ": " + err.Error())
+			}
+		}
+	} else {
+		target := ref
+		if target == "" {
+			target = "HEAD"
+		}
+
+		if _, err := git(dir, "fetch", "--quiet", "--force", "origin", target); err != nil {
+			return "", "", errors.New("failed to fetch " + target + " of " + repo + ": " + err.Error())
+		}
+
+		commit, err = git(dir, "rev-parse", "FETCH_HEAD")
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if _, err := git(dir, "checkout", "--quiet", "--force", "--detach", commit); err != nil {
+		return "", "", errors.New("failed to check out " + commit + " of " + repo + ": " + err.Error())
+	}
+
+	sum, err := fileSha256(file)
+	if err != nil {
+		return "", "", errors.New("remote import " + uri + ": " + name + " does not exist at " + commit)
+	}
+
+	if locked && !ri.update && sum != entry.Sha256 {
+		return "", "", errors.New("remote import " + uri + " does not match " + LockFileName + ": expected sha256 " + entry.Sha256 + ", got " + sum)
+	}
+
+	ri.pin(uri, lockEntry{Sha256: sum, Commit: commit})
+	return file, dir, nil
+}
+
+func (ri *remoteImports) pin(uri string, entry lockEntry) {
+	if ri.lock.Imports[uri] != entry {
+		ri.lock.Imports[uri] = entry
+		ri.changed = true
+	}
+}
+
+// save writes the lock file when an import was pinned. An update also
+// removes the entries of imports that are no longer used.
+func (ri *remoteImports) save() error {
+	if ri.update {
+		for uri := range ri.lock.Imports {
+			if !ri.used[uri] {
+				delete(ri.lock.Imports, uri)
+				ri.changed = true
+			}
+		}
+	}
+
+	if !ri.changed {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(ri.lock); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(ri.lockPath, buf.Bytes(), 0644); err != nil {
+		return errors.New("failed to write " + ri.lockPath + ": " + err.Error())
+	}
+
+	ri.changed = false
+	return nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	exe, err := exec.Find("git", nil)
+	if err != nil || exe == "" {
+		return "", errors.New("git not found, set XTASK_GIT_EXE to the path of the executable")
+	}
+
+	out := &bytes.Buffer{}
+	cmd := exec.New(exe, args...).
+		WithCwd(dir).
+		WithEnv(append(os.Environ(), "GIT_TERMINAL_PROMPT=0")...).
+		WithStdout(out).
+		WithStderr(out)
+	if err = cmd.Start(); err == nil {
+		err = cmd.Wait()
+	}
+
+	if err != nil {
+		msg := strings.TrimSpace(out.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", errors.New("git " + args[0] + ": " + msg)
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+func cacheKey(s string) string {
+	return sha256Hex([]byte(s))[:16]
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func fileSha256(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return sha256Hex(data), nil
+}
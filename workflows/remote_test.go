@@ -0,0 +1,173 @@
+package workflows
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGitImport(t *testing.T) {
+	repo, file, ref, err := parseGitImport("git+https://example.com/org/lib.git//tasks/build.xtask.yaml@v1.2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/org/lib.git", repo)
+	assert.Equal(t, "tasks/build.xtask.yaml", file)
+	assert.Equal(t, "v1.2.0", ref)
+
+	repo, file, ref, err = parseGitImport("git+file:///srv/git/lib.git//ci.xtask.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "file:///srv/git/lib.git", repo)
+	assert.Equal(t, "ci.xtask.yaml", file)
+	assert.Equal(t, "", ref)
+
+	_, _, _, err = parseGitImport("git+https://example.com/org/lib.git")
+	assert.Error(t, err)
+}
+
+func TestRemoteImportsHTTP(t *testing.T) {
+	files := map[string]string{
+		"/ci/ci.xtask.yaml":   "imports: [lint.xtask.yaml]\ntasks:\n  build: go build ./...\n",
+		"/ci/lint.xtask.yaml": "tasks:\n  lint: go vet ./...\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	t.Setenv("XTASK_CACHE_HOME", cache)
+
+	load := func(update bool, offline bool) (*types.XTaskfile, error) {
+		tf := types.NewXTaskfile()
+		tf.Path = filepath.Join(dir, "xtaskfile")
+		tf.Imports = types.Imports{{Uri: server.URL + "/ci/ci.xtask.yaml", Namespace: "ci"}}
+
+		remote, err := newRemoteImports(dir, update, offline)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := resolveImports(tf, remote); err != nil {
+			return nil, err
+		}
+
+		return tf, remote.save()
+	}
+
+	tf, err := load(false, false)
+	assert.NoError(t, err)
+	assert.Contains(t, *tf.Tasks, "ci:build")
+	assert.Contains(t, *tf.Tasks, "ci:lint")
+
+	lock, err := os.ReadFile(filepath.Join(dir, LockFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(lock), server.URL+"/ci/ci.xtask.yaml")
+	assert.Contains(t, string(lock), server.URL+"/ci/lint.xtask.yaml")
+
+	// the cached files are used without the server.
+	_, err = load(false, true)
+	assert.NoError(t, err)
+
+	// a changed import does not match the lock file until it is updated.
+	files["/ci/lint.xtask.yaml"] = "tasks:\n  lint: golangci-lint run\n"
+	assert.NoError(t, os.RemoveAll(cache))
+
+	_, err = load(false, true)
+	assert.ErrorContains(t, err, "is not cached")
+
+	_, err = load(false, false)
+	assert.ErrorContains(t, err, "does not match "+LockFileName)
+
+	tf, err = load(true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "golangci-lint run", *(*tf.Tasks)["ci:lint"].Run)
+
+	remote, err := newRemoteImports(dir, false, false)
+	assert.NoError(t, err)
+	_, _, err = remote.fetch("http://example.com/ci/ci.xtask.yaml")
+	assert.ErrorContains(t, err, "must use https")
+	_, _, err = remote.fetch("git+http://example.com/org/lib.git//ci.xtask.yaml@v1")
+	assert.ErrorContains(t, err, "must use https")
+
+	// a redirect to plain http is refused as well.
+	redirect := httptest.NewServer(http.RedirectHandler("http://example.com/ci/ci.xtask.yaml", http.StatusFound))
+	defer redirect.Close()
+	_, _, err = remote.fetch(redirect.URL + "/ci/ci.xtask.yaml")
+	assert.ErrorContains(t, err, "must use https")
+}
+
+func TestRemoteImportsGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "lib.git")
+	work := filepath.Join(dir, "work")
+	run := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	run(dir, "init", "--quiet", "--bare", bare)
+	run(dir, "clone", "--quiet", bare, work)
+	writeFile(t, filepath.Join(work, "tasks", "build.xtask.yaml"), "run: echo v1\n", 0644)
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "v1")
+	run(work, "tag", "v1")
+	run(work, "push", "--quiet", "origin", "HEAD", "v1")
+	v1 := run(work, "rev-parse", "HEAD")
+
+	t.Setenv("XTASK_CACHE_HOME", filepath.Join(dir, "cache"))
+	uri := "git+file://" + filepath.ToSlash(bare) + "//tasks/build.xtask.yaml@v1"
+
+	remote, err := newRemoteImports(dir, false, false)
+	assert.NoError(t, err)
+	file, _, err := remote.fetch(uri)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.save())
+	assert.Equal(t, v1, remote.lock.Imports[uri].Commit)
+
+	data, _ := os.ReadFile(file)
+	assert.Equal(t, "run: echo v1\n", string(data))
+
+	// moving the tag does not change the pinned commit until an update.
+	writeFile(t, filepath.Join(work, "tasks", "build.xtask.yaml"), "run: echo v2\n", 0644)
+	run(work, "commit", "--quiet", "-am", "v2")
+	run(work, "tag", "--force", "v1")
+	run(work, "push", "--quiet", "--force", "origin", "HEAD", "v1")
+
+	remote, err = newRemoteImports(dir, false, false)
+	assert.NoError(t, err)
+	file, _, err = remote.fetch(uri)
+	assert.NoError(t, err)
+	data, _ = os.ReadFile(file)
+	assert.Equal(t, "run: echo v1\n", string(data))
+
+	remote, err = newRemoteImports(dir, true, false)
+	assert.NoError(t, err)
+	file, _, err = remote.fetch(uri)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.save())
+	data, _ = os.ReadFile(file)
+	assert.Equal(t, "run: echo v2\n", string(data))
+	assert.NotEqual(t, v1, remote.lock.Imports[uri].Commit)
+}
@@ -0,0 +1,51 @@
+package workflows
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 5 * time.Minute
+)
+
+// retryDelay returns the time to wait before the given retry. The delay
+// doubles with each retry, is capped at maxRetryDelay and has up to 20%
+// of jitter added so that tasks retried at the same time spread out.
+func retryDelay(base time.Duration, retry int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := 1; i < retry && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	jitter := time.Duration(rand.Int64N(int64(delay)/5 + 1))
+	return delay + jitter
+}
+
+// sleepContext waits for d to pass or for ctx to be done, whichever
+// happens first, and returns the error of ctx in the latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
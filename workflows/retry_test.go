@@ -0,0 +1,31 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDelayBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	for retry, want := range map[int]time.Duration{1: base, 2: 2 * base, 3: 4 * base} {
+		d := retryDelay(base, retry)
+		assert.GreaterOrEqual(t, d, want)
+		assert.LessOrEqual(t, d, want+want/5)
+	}
+
+	assert.Equal(t, time.Duration(0), retryDelay(0, 3))
+	assert.LessOrEqual(t, retryDelay(time.Minute, 20), maxRetryDelay+maxRetryDelay/5)
+}
+
+func TestSleepContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := sleepContext(ctx, time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -2,20 +2,51 @@ package workflows
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"html/template"
+	"io"
+	"maps"
 	"os"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/sprig"
 	"github.com/hyprxlabs/go/dotenv"
 	"github.com/hyprxlabs/go/env"
+	"github.com/hyprxlabs/xtask/statuses"
 	"github.com/hyprxlabs/xtask/tasks"
 	"github.com/hyprxlabs/xtask/types"
 )
 
+// runState is shared by all tasks of a single run. The env is updated by
+// the XTASK_ENV and XTASK_PATH files of completed tasks and must only be
+// accessed while holding mu.
+type runState struct {
+	mu  sync.Mutex
+	env *types.Env
+	// params holds the params and args of the tasks named on the command
+	// line. Other tasks do not get the args.
+	params map[string]*taskParams
+	// matrix holds the values of the combinations of matrix tasks.
+	matrix  map[string]map[string]string
+	results map[string]*tasks.TaskResult
+	step    int
+	// sshPool keeps the ssh connections of the run open so that tasks
+	// on the same host reuse them.
+	sshPool *tasks.SSHPool
+	// closers holds the results of tasks that left something running,
+	// e.g. an ssh tunnel, until the tasks that need them have completed.
+	closers map[string]io.Closer
+	users   map[string]int // task id to the number of tasks that need it and did not complete
+	// facts holds the os gathered for each host alias during the run.
+	facts map[string]*types.OS
+}
+
 func (ws *Workflow) Run(taskNames []string, args []string) error {
 	if ws == nil {
 		return errors.New("workflow is nil")
@@ -36,23 +67,14 @@ func (ws *Workflow) Run(taskNames []string, args []string) error {
 		return &CyclicalReferenceError{Cycles: cycles}
 	}
 
-	flatTasks, err := flattenTasks(taskNames, ws.Tasks, []types.Task{})
+	expanded, matrix, err := expandMatrix(ws.Tasks)
 	if err != nil {
 		return err
 	}
 
-	// skip last task if there is more than one task and
-	// the last task has no run or uses defined
-	lastId := ""
-	if len(flatTasks) > 1 {
-		last := flatTasks[len(flatTasks)-1]
-		if last.Uses == nil || len(*last.Uses) == 0 {
-
-			if last.Run == nil || len(*last.Run) == 0 {
-				lastId = last.Id
-
-			}
-		}
+	graph, err := buildTaskGraph(taskNames, expanded)
+	if err != nil {
+		return err
 	}
 
 	if ws.cleanupEnv {
@@ -79,418 +101,787 @@ func (ws *Workflow) Run(taskNames []string, args []string) error {
 
 	envMap := ws.Env.Clone()
 	if envMap.Has("XTASK_ENV") && !ws.cleanupEnv {
-		envFile := envMap.GetString("XTASK_ENV")
-		if len(envFile) > 0 {
-			canOpen := true
-			if _, err := os.Stat(envFile); err != nil {
-				canOpen = false
-			}
+		if err := ws.mergeEnvFile(envMap.GetString("XTASK_ENV"), envMap); err != nil {
+			return err
+		}
+	}
 
-			if canOpen {
-				bytes, err := os.ReadFile(envFile)
-				if err != nil {
-					return errors.New("Failed to read XTASK_ENV file: " + err.Error())
-				}
+	if ws.DryRun {
+		os.Stdout.WriteString("\x1b[1mPlan for " + strings.Join(taskNames, ", ") + "\x1b[22m (dry run, no tasks are run)\n")
+	}
 
-				if len(bytes) > 0 {
-					opts := &env.ExpandOptions{
-						Get: func(key string) string {
-							val, ok := envMap.Get(key)
-							if ok {
-								return val
-							}
-							return ""
-						},
-						Set: func(key, value string) error {
-							envMap.Set(key, value)
-							return nil
-						},
-						Keys:                envMap.Keys(),
-						ExpandUnixArgs:      true,
-						ExpandWindowsVars:   false,
-						CommandSubstitution: ws.Config.Substitution,
-					}
-					doc2, err := dotenv.Parse(string(bytes))
-					if err != nil {
-						return errors.New("Failed to parse XTASK_ENV file: " + err.Error())
-					}
+	params := map[string]*taskParams{}
+	for _, name := range taskNames {
+		task, ok := ws.Tasks[name]
+		if !ok {
+			continue
+		}
 
-					for _, node := range doc2.ToArray() {
-						if node.Type == dotenv.VARIABLE_TOKEN {
-							key := ""
-							value := node.Value
-							if node.Key != nil {
-								key = *node.Key
-							}
-
-							if strings.HasPrefix("XTASK_", key) {
-								if strings.HasSuffix(key, "_EXE") {
-									value, err := env.ExpandWithOptions(value, opts)
-									if err != nil {
-										return errors.New("Failed to expand environment variable: " + err.Error())
-									}
-
-									envMap.Set(key, value)
-								}
-								continue
-							}
-
-							value, err := env.ExpandWithOptions(value, opts)
-							if err != nil {
-								return errors.New("Failed to expand environment variable: " + err.Error())
-							}
-							envMap.Set(key, value)
-						}
-					}
-				}
+		p, err := parseParams(task, args)
+		if err != nil {
+			return err
+		}
+		params[name] = p
+
+		// the combinations of a matrix task get its params.
+		if task.Matrix != nil {
+			for _, need := range expanded[name].Needs {
+				params[need.Name] = p
 			}
 		}
 	}
 
-	hostGroups := map[string][]types.Host{}
-	for name, host := range ws.Hosts {
-		if len(host.Groups) > 0 {
-			for _, group := range host.Groups {
-				if _, ok := hostGroups[group]; !ok {
-					hostGroups[group] = []types.Host{}
-				}
-				hostGroups[group] = append(hostGroups[group], host)
-			}
+	state := &runState{
+		env:     envMap,
+		params:  params,
+		matrix:  matrix,
+		results: map[string]*tasks.TaskResult{},
+		sshPool: tasks.NewSSHPool(),
+		closers: map[string]io.Closer{},
+		users:   map[string]int{},
+		facts:   map[string]*types.OS{},
+	}
+	defer state.sshPool.Close()
+
+	for _, node := range graph.nodes {
+		for _, need := range node.task.Needs {
+			state.users[need.Name]++
 		}
+	}
 
-		if _, ok := hostGroups[name]; !ok {
-			hostGroups[name] = []types.Host{host}
-		} else {
-			hostGroups[name] = append(hostGroups[name], host)
+	// tunnels use the connections of the pool, so they are closed first.
+	defer state.closeAll()
+
+	// a single job keeps the plan in the order the tasks would run.
+	jobs := ws.Jobs
+	if ws.DryRun {
+		jobs = 1
+	}
+
+	err = schedule(graph, jobs, func(node *taskNode) error {
+		err := ws.runTask(state, node.task)
+		state.release(node.task)
+		return err
+	})
+
+	ws.Results = state.results
+
+	if !ws.DryRun {
+		ws.printMatrixReport(graph, state.results)
+	}
+
+	// tasks that are allowed to fail are still reported as failed.
+	failed := []string{}
+	for _, node := range graph.nodes {
+		result, ok := state.results[node.task.Id]
+		if ok && node.task.ContinueOnError && (result.Status == statuses.Error || result.Status == statuses.Cancelled) {
+			failed = append(failed, node.task.Id)
 		}
 	}
 
-	for _, task := range flatTasks {
-		taskEnv := envMap.Clone()
+	if len(failed) > 0 {
+		os.Stderr.WriteString("\x1b[33mTasks failed with continue-on-error: " + strings.Join(failed, ", ") + "\x1b[39m\n")
+	}
 
-		if lastId != "" && task.Id == lastId {
-			name := task.Id
-			if task.Name != nil && len(*task.Name) > 0 {
-				name = *task.Name
-			}
+	return err
+}
 
-			os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m\n")
+func (ws *Workflow) runTask(state *runState, task types.Task) error {
+	// a task without run or uses only groups its needs.
+	if (task.Uses == nil || len(*task.Uses) == 0) && (task.Run == nil || len(*task.Run) == 0) {
+		name := task.Id
+		if task.Name != nil && len(*task.Name) > 0 {
+			name = *task.Name
+		}
+
+		if ws.DryRun {
+			ws.printPlan(state, planStep{task: task, name: name, groupOnly: true})
 			return nil
 		}
 
-		f, err := os.CreateTemp("", "xtask-env-")
-		if err != nil {
-			return err
+		os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m\n")
+		return nil
+	}
+
+	parentCtx := ws.Context
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+
+	if err := parentCtx.Err(); err != nil {
+		return errors.New("workflow cancelled before task " + task.Id + " started")
+	}
+
+	state.mu.Lock()
+	taskEnv := state.env.Clone()
+	results := maps.Clone(state.results)
+	state.mu.Unlock()
+
+	expand := func(s string) (string, error) {
+		// outputs are not available as no task runs.
+		if ws.DryRun {
+			return s, nil
 		}
-		f.Write([]byte{})
-		f.Close()
-		taskEnv.Set("XTASK_ENV", f.Name())
 
-		defer func() {
-			if isFile(f.Name()) {
-				os.Remove(f.Name())
+		return expandOutputs(s, results)
+	}
+
+	args := []string{}
+	params := map[string]interface{}{}
+	if p, ok := state.params[task.Id]; ok {
+		args = p.args
+		params = p.values
+	}
+
+	f, err := os.CreateTemp("", "xtask-env-")
+	if err != nil {
+		return err
+	}
+	f.Write([]byte{})
+	f.Close()
+	taskEnv.Set("XTASK_ENV", f.Name())
+
+	defer func() {
+		if isFile(f.Name()) {
+			os.Remove(f.Name())
+		}
+	}()
+
+	f2, err := os.CreateTemp("", "xtask-path-")
+	if err != nil {
+		return err
+	}
+	f2.Write([]byte{})
+	f2.Close()
+	taskEnv.Set("XTASK_PATH", f2.Name())
+
+	defer func() {
+		if isFile(f2.Name()) {
+			os.Remove(f2.Name())
+		}
+	}()
+	f3, err := os.CreateTemp("", "xtask-output-")
+	if err != nil {
+		return err
+	}
+	f3.Close()
+	outputFile := f3.Name()
+	taskEnv.Set("XTASK_OUTPUT", outputFile)
+
+	defer func() {
+		if isFile(outputFile) {
+			os.Remove(outputFile)
+		}
+	}()
+
+	if task.Name == nil || len(*task.Name) == 0 {
+		task.Name = &task.Id
+	}
+
+	uses := ws.Config.Shell
+	if task.Uses != nil && len(*task.Uses) > 0 {
+		uses = *task.Uses
+	}
+
+	desc := ""
+	if task.Desc != nil {
+		desc = *task.Desc
+	}
+
+	help := ""
+	if task.Help != nil {
+		help = *task.Help
+	}
+
+	cwd := ""
+	if task.Cwd != nil && len(*task.Cwd) > 0 {
+		cwd = *task.Cwd
+	}
+	cwd, err = expand(cwd)
+	if err != nil {
+		return errors.New("failed to expand cwd for task " + task.Id + ": " + err.Error())
+	}
+	if len(cwd) == 0 {
+		c, ok := ws.Env.Get("XTASK_DIR")
+		if ok {
+			cwd = c
+		} else {
+			c, err := os.Getwd()
+			if err != nil {
+				return err
 			}
-		}()
+			cwd = c
+		}
+	}
 
-		f2, err := os.CreateTemp("", "xtask-path-")
+	var timeout time.Duration
+	timeout = 0
+	if task.Timeout != nil && len(*task.Timeout) > 0 {
+		t, err := time.ParseDuration(*task.Timeout)
 		if err != nil {
 			return err
 		}
-		f2.Write([]byte{})
-		f2.Close()
-		taskEnv.Set("XTASK_PATH", f2.Name())
+		timeout = t
+	}
 
-		defer func() {
-			if isFile(f2.Name()) {
-				os.Remove(f2.Name())
-			}
-		}()
+	retryBase := defaultRetryDelay
+	if task.RetryDelay != nil && len(*task.RetryDelay) > 0 {
+		d, err := time.ParseDuration(*task.RetryDelay)
+		if err != nil {
+			return errors.New("invalid retry-delay for task " + task.Id + ": " + err.Error())
+		}
+		retryBase = d
+	}
+
+	run := ""
+	if task.Run != nil && len(*task.Run) > 0 {
+		run, err = expand(*task.Run)
+		if err != nil {
+			return errors.New("failed to expand run for task " + task.Id + ": " + err.Error())
+		}
+		task.Run = &run
+	}
 
-		if task.Name == nil || len(*task.Name) == 0 {
-			task.Name = &task.Id
+	if len(task.With) > 0 && !ws.DryRun {
+		with, err := expandOutputsIn(task.With, results)
+		if err != nil {
+			return errors.New("failed to expand with for task " + task.Id + ": " + err.Error())
 		}
+		task.With = with.(map[string]interface{})
+	}
 
-		uses := ws.Config.Shell
-		if task.Uses != nil && len(*task.Uses) > 0 {
-			uses = *task.Uses
+	inputs := map[string]interface{}{}
+	if len(task.Inputs) > 0 {
+		// the default shell does not read with.
+		options := []string{}
+		if uses != "" {
+			options = tasks.RunnerOptions(uses)
 		}
 
-		desc := ""
-		if task.Desc != nil {
-			desc = *task.Desc
+		inputs, err = resolveInputs(task.Inputs, task.With, options)
+		if err != nil {
+			return errors.New("invalid with for task " + task.Id + ": " + err.Error())
 		}
 
-		help := ""
-		if task.Help != nil {
-			help = *task.Help
+		for id, value := range inputs {
+			taskEnv.Set(envVarName("INPUT_", id), formatOutput(value))
 		}
 
-		cwd := ""
-		if task.Cwd != nil && len(*task.Cwd) > 0 {
-			cwd = *task.Cwd
+		run, err = expandValues(run, "inputs", inputs)
+		if err != nil {
+			return errors.New("failed to expand run for task " + task.Id + ": " + err.Error())
 		}
-		if len(cwd) == 0 {
-			c, ok := ws.Env.Get("XTASK_DIR")
-			if ok {
-				cwd = c
-			} else {
-				c, err := os.Getwd()
-				if err != nil {
-					return err
-				}
-				cwd = c
-			}
+		task.Run = &run
+	}
+
+	matrix := map[string]interface{}{}
+	for key, value := range state.matrix[task.Id] {
+		matrix[key] = value
+	}
+
+	if len(matrix) > 0 {
+		for key, value := range matrix {
+			taskEnv.Set(envVarName("MATRIX_", key), formatOutput(value))
 		}
 
-		var timeout time.Duration
-		timeout = 0
-		if task.Timeout != nil && len(*task.Timeout) > 0 {
-			t, err := time.ParseDuration(*task.Timeout)
-			if err != nil {
-				return err
-			}
-			timeout = t
+		run, err = expandValues(run, "matrix", matrix)
+		if err != nil {
+			return errors.New("failed to expand run for task " + task.Id + ": " + err.Error())
 		}
+		task.Run = &run
+	}
 
-		run := ""
-		if task.Run != nil && len(*task.Run) > 0 {
-			run = *task.Run
+	if len(params) > 0 {
+		for name, value := range params {
+			taskEnv.Set(envVarName("PARAM_", name), formatOutput(value))
 		}
 
-		hosts := map[string]types.Host{}
-		if len(task.Hosts) > 0 {
-			for _, h := range task.Hosts {
-				if groupHosts, ok := hostGroups[h]; ok {
-					for _, gh := range groupHosts {
-						hosts[gh.Host] = gh
-					}
-				}
+		run, err = expandValues(run, "params", params)
+		if err != nil {
+			return errors.New("failed to expand run for task " + task.Id + ": " + err.Error())
+		}
+		task.Run = &run
+	}
+
+	hosts := map[string]types.Host{}
+	if len(task.Hosts) > 0 {
+		aliases, err := selectHosts(task.Hosts, ws.Hosts)
+		if err != nil {
+			return errors.New("failed to select hosts for task " + task.Id + ": " + err.Error())
+		}
+
+		// --limit narrows the hosts of every task.
+		if ws.Limit != "" {
+			limit, err := selectHosts([]string{ws.Limit}, ws.Hosts)
+			if err != nil {
+				return errors.New("invalid limit: " + err.Error())
 			}
+
+			aliases = slices.DeleteFunc(aliases, func(alias string) bool {
+				return !slices.Contains(limit, alias)
+			})
 		}
 
-		opts := &env.ExpandOptions{
-			Get: func(key string) string {
-				val, ok := taskEnv.Get(key)
-				if ok {
-					return val
-				}
-				return ""
-			},
-			Set: func(key, value string) error {
-				taskEnv.Set(key, value)
-				return nil
-			},
-			Keys:                taskEnv.Keys(),
-			ExpandUnixArgs:      true,
-			ExpandWindowsVars:   false,
-			CommandSubstitution: ws.Config.Substitution,
+		for _, alias := range aliases {
+			hosts[alias] = ws.Hosts[alias]
 		}
+	}
 
-		if task.Env.Len() > 0 {
+	opts := &env.ExpandOptions{
+		Get: func(key string) string {
+			val, ok := taskEnv.Get(key)
+			if ok {
+				return val
+			}
+			return ""
+		},
+		Set: func(key, value string) error {
+			taskEnv.Set(key, value)
+			return nil
+		},
+		Keys:                taskEnv.Keys(),
+		ExpandUnixArgs:      true,
+		ExpandWindowsVars:   false,
+		CommandSubstitution: ws.Config.Substitution && !ws.DryRun,
+	}
 
-			for k, v := range task.Env.Iter() {
+	if task.Env.Len() > 0 {
 
-				ev, err := env.ExpandWithOptions(v, opts)
-				if err != nil {
-					return errors.New("failed to expand env var: " + k + " for task: " + task.Id + " error: " + err.Error())
-				}
-				taskEnv.Set(k, ev)
-				hasKey := false
-				for _, keys := range opts.Keys {
-					if keys == k {
-						hasKey = true
-						break
-					}
-				}
+		for k, v := range task.Env.Iter() {
 
-				if !hasKey {
-					opts.Keys = append(opts.Keys, k)
-				}
+			v, err := expand(v)
+			if err != nil {
+				return errors.New("failed to expand env var: " + k + " for task: " + task.Id + " error: " + err.Error())
 			}
-		}
 
-		if strings.ContainsRune(cwd, '$') {
-			c, err := env.ExpandWithOptions(cwd, opts)
+			ev, err := env.ExpandWithOptions(v, opts)
 			if err != nil {
-				return errors.New("failed to expand cwd: " + cwd + " for task: " + task.Id + " error: " + err.Error())
+				return errors.New("failed to expand env var: " + k + " for task: " + task.Id + " error: " + err.Error())
+			}
+			taskEnv.Set(k, ev)
+			hasKey := false
+			for _, keys := range opts.Keys {
+				if keys == k {
+					hasKey = true
+					break
+				}
+			}
+
+			if !hasKey {
+				opts.Keys = append(opts.Keys, k)
 			}
-			cwd = c
 		}
+	}
 
-		data := &tasks.TaskData{
-			Env:     *taskEnv,
-			Id:      task.Id,
-			Hosts:   hosts,
-			Uses:    uses,
-			Desc:    desc,
-			Help:    help,
-			Run:     run,
-			Needs:   task.Needs,
-			With:    task.With,
-			Cwd:     cwd,
-			Timeout: timeout,
+	if strings.ContainsRune(cwd, '$') {
+		c, err := env.ExpandWithOptions(cwd, opts)
+		if err != nil {
+			return errors.New("failed to expand cwd: " + cwd + " for task: " + task.Id + " error: " + err.Error())
 		}
+		cwd = c
+	}
+
+	data := &tasks.TaskData{
+		Env:     *taskEnv,
+		Id:      task.Id,
+		Hosts:   hosts,
+		Uses:    uses,
+		Desc:    desc,
+		Help:    help,
+		Run:     run,
+		Needs:   task.Needs.Names(),
+		With:    task.With,
+		Cwd:     cwd,
+		Timeout: timeout,
+	}
 
-		taskCtx := &tasks.TaskContext{
-			Task:        task,
-			Data:        *data,
-			Args:        args,
-			Context:     ws.Context,
-			ContextName: ws.ContextName,
+	taskCtx := &tasks.TaskContext{
+		Task:        task,
+		Data:        *data,
+		Args:        args,
+		Context:     parentCtx,
+		ContextName: ws.ContextName,
+		Inventory:   ws.Hosts,
+		SSHPool:     state.sshPool,
+	}
+
+	name := data.Id
+	if task.Name != nil && len(*task.Name) > 0 {
+		name = *task.Name
+	}
+
+	predicate := true
+	skipReason := "if evaluated to false"
+	if len(hosts) > 0 && !ws.DryRun && ws.needsFacts(task) {
+		if err := ws.gatherFacts(state, taskEnv, hosts); err != nil {
+			return errors.New("failed to gather facts for task " + task.Id + ": " + err.Error())
 		}
+	}
 
-		name := data.Id
-		if task.Name != nil && len(*task.Name) > 0 {
-			name = *task.Name
+	if len(task.Hosts) > 0 && len(hosts) == 0 && ws.Limit != "" {
+		predicate = false
+		skipReason = "no hosts match the limit " + ws.Limit
+	}
+
+	if len(hosts) > 0 && len(task.OS) > 0 && !ws.DryRun {
+		filterHostsByOS(hosts, task.OS)
+		if len(hosts) == 0 {
+			predicate = false
+			skipReason = "no hosts match os " + strings.Join(task.OS, ", ")
 		}
+	}
 
-		predicate := true
-		if task.Predicate != nil && len(*task.Predicate) > 0 {
-			predicateRaw := *task.Predicate
-			if predicateRaw == "0" || strings.EqualFold(predicateRaw, "false") {
-				predicate = false
-			} else if predicateRaw == "1" || strings.EqualFold(predicateRaw, "true") {
-				predicate = true
-			} else {
-				predicate = false
-			}
+	if predicate && task.Predicate != nil && len(*task.Predicate) > 0 {
+		predicateRaw, err := expand(*task.Predicate)
+		if err != nil {
+			return errors.New("failed to expand if section for task " + task.Id + ": " + err.Error())
+		}
 
-			tplData := map[string]interface{}{
-				"env":  taskEnv.ToMap(),
-				"os":   runtime.GOOS,
-				"arch": runtime.GOARCH,
-			}
+		tmp, err := template.New(task.Id + "." + "if").Funcs(sprig.FuncMap()).Parse(predicateRaw)
+		if err != nil {
+			return errors.New("failed to parse if section for task " + task.Id + ": " + err.Error())
+		}
 
-			tmp, err := template.New(task.Id + "." + "if").Funcs(sprig.FuncMap()).Parse(predicateRaw)
-			if err != nil {
-				return errors.New("failed to parse if section for task " + task.Id + ": " + err.Error())
+		evalIf := func(host map[string]interface{}) (bool, error) {
+			tplData := map[string]interface{}{
+				"env":    taskEnv.ToMap(),
+				"os":     runtime.GOOS,
+				"arch":   runtime.GOARCH,
+				"tasks":  outputData(results),
+				"host":   host,
+				"inputs": inputs,
+				"params": params,
+				"matrix": matrix,
 			}
 
 			out := &strings.Builder{}
 			if err := tmp.Execute(out, tplData); err != nil {
-				return errors.New("failed to execute template for task " + task.Id + ": " + err.Error())
+				return false, errors.New("failed to execute template for task " + task.Id + ": " + err.Error())
 			}
 
 			output := strings.TrimSpace(out.String())
-			if output == "1" || strings.EqualFold(output, "true") {
-				predicate = true
+			return output == "1" || strings.EqualFold(output, "true"), nil
+		}
+
+		// an if that tests the host is evaluated for each host and only
+		// keeps the hosts it is true for.
+		if len(hosts) > 0 && strings.Contains(predicateRaw, ".host") {
+			if !ws.DryRun {
+				for alias, host := range hosts {
+					ok, err := evalIf(tasks.HostData(alias, host))
+					if err != nil {
+						return err
+					}
+
+					if !ok {
+						delete(hosts, alias)
+					}
+				}
+
+				predicate = len(hosts) > 0
+			}
+		} else {
+			predicate, err = evalIf(tasks.HostData("", types.Host{}))
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		if !predicate {
-			os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m (skipped)\n")
-			continue
+	if !predicate && !ws.DryRun {
+		os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m (skipped)\n")
+		state.mu.Lock()
+		state.results[task.Id] = tasks.NewTaskResult().Skip(skipReason)
+		state.mu.Unlock()
+		return nil
+	}
+
+	var fp *fingerprint
+	upToDate := false
+	if len(task.Sources) > 0 || len(task.Generates) > 0 {
+		fp, err = newFingerprint(taskEnv.GetString("XTASK_CACHE_HOME"), taskEnv.GetString("XTASK_FILE"), task, cwd, run, uses, args, taskEnv)
+		if err != nil {
+			return err
 		}
 
-		os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m\n")
-		result := tasks.Run(*taskCtx)
+		upToDate = !ws.Force && fp.upToDate(cwd, task.Generates)
+		if upToDate && !ws.DryRun {
+			os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m (up to date)\n")
+			state.mu.Lock()
+			// the outputs of the last run are used by the tasks that need it.
+			result := tasks.NewTaskResult().Skip("sources and generates are up to date")
+			for k, v := range fp.outputs {
+				result.Output[k] = v
+			}
+			state.results[task.Id] = result
+			state.mu.Unlock()
+			return nil
+		}
+	}
+
+	if ws.DryRun {
+		ws.printPlan(state, planStep{
+			task:      task,
+			name:      name,
+			data:      data,
+			predicate: predicate,
+			upToDate:  upToDate,
+		})
+		return nil
+	}
+
+	os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m\n")
+
+	// each attempt gets the full timeout of the task.
+	runAttempt := func() *tasks.TaskResult {
+		if timeout > 0 {
+			c, cancel := context.WithTimeout(parentCtx, timeout)
+			defer cancel()
+			taskCtx.Context = c
+		}
+
+		return tasks.Run(*taskCtx)
+	}
+
+	attempts := task.Retries + 1
+	var result *tasks.TaskResult
+	for attempt := 1; ; attempt++ {
+		result = runAttempt()
+		result.Attempts = attempt
+
+		if result.Err == nil && result.Status != statuses.Error && result.Status != statuses.Cancelled {
+			break
+		}
 
+		// a cancelled workflow is never retried.
+		if attempt >= attempts || parentCtx.Err() != nil {
+			break
+		}
+
+		reason := result.Message
 		if result.Err != nil {
-			return result.Err
+			reason = result.Err.Error()
 		}
 
-		envFile := taskEnv.GetString("XTASK_ENV")
-		if len(envFile) > 0 {
-			canOpen := true
-			if _, err := os.Stat(envFile); err != nil {
-				canOpen = false
-			}
+		delay := retryDelay(retryBase, attempt)
+		os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m failed (attempt " + strconv.Itoa(attempt) + "/" + strconv.Itoa(attempts) + "): " + reason + "\n")
+		os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m retrying in " + delay.Round(time.Millisecond).String() + "\n")
 
-			if canOpen {
-				bytes, err := os.ReadFile(envFile)
-				if err != nil {
-					return errors.New("Failed to read XTASK_ENV file: " + err.Error())
-				}
+		// discard anything written by the failed attempt.
+		os.WriteFile(taskEnv.GetString("XTASK_ENV"), []byte{}, 0644)
+		os.WriteFile(taskEnv.GetString("XTASK_PATH"), []byte{}, 0644)
+		os.WriteFile(outputFile, []byte{}, 0644)
 
-				if len(bytes) > 0 {
-					opts := &env.ExpandOptions{
-						Get: func(key string) string {
-							val, ok := envMap.Get(key)
-							if ok {
-								return val
-							}
-							return ""
-						},
-						Set: func(key, value string) error {
-							envMap.Set(key, value)
-							return nil
-						},
-						Keys:                envMap.Keys(),
-						ExpandUnixArgs:      true,
-						ExpandWindowsVars:   false,
-						CommandSubstitution: ws.Config.Substitution,
-					}
-					doc2, err := dotenv.Parse(string(bytes))
-					if err != nil {
-						return errors.New("Failed to parse XTASK_ENV file: " + err.Error())
-					}
+		if err := sleepContext(parentCtx, delay); err != nil {
+			break
+		}
+	}
 
-					for _, node := range doc2.ToArray() {
-						if node.Type == dotenv.VARIABLE_TOKEN {
-							key := ""
-							value := node.Value
-							if node.Key != nil {
-								key = *node.Key
-							}
-
-							if strings.HasPrefix("XTASK_", key) {
-								if strings.HasSuffix(key, "_EXE") {
-									value, err := env.ExpandWithOptions(value, opts)
-									if err != nil {
-										return errors.New("Failed to expand environment variable: " + err.Error())
-									}
-
-									envMap.Set(key, value)
-								}
-								continue
-							}
-
-							value, err := env.ExpandWithOptions(value, opts)
-							if err != nil {
-								return errors.New("Failed to expand environment variable: " + err.Error())
-							}
-							envMap.Set(key, value)
-						}
-					}
-				}
+	if result.Err == nil && result.Status == statuses.Ok {
+		outputs, err := readOutputFile(outputFile, task.Outputs)
+		if err != nil {
+			result.Fail(errors.New("task " + task.Id + ": " + err.Error()))
+		} else {
+			if result.Output == nil {
+				result.Output = map[string]interface{}{}
 			}
 
-			if isFile(envFile) {
-				os.Remove(envFile)
+			for k, v := range outputs {
+				result.Output[k] = v
 			}
 		}
+	}
 
-		pathFile := taskEnv.GetString("XTASK_PATH")
-		if len(pathFile) > 0 {
+	// the fingerprint is taken again as the task may change its sources,
+	// e.g. when formatting code.
+	if fp != nil && result.Err == nil && result.Status == statuses.Ok {
+		fp, err = newFingerprint(taskEnv.GetString("XTASK_CACHE_HOME"), taskEnv.GetString("XTASK_FILE"), task, cwd, run, uses, args, taskEnv)
+		if err == nil {
+			err = fp.save(result.Output)
+		}
 
-			canOpen := true
-			if _, err := os.Stat(pathFile); err != nil {
-				canOpen = false
+		if err != nil {
+			os.Stderr.WriteString("failed to save fingerprint for task " + task.Id + ": " + err.Error() + "\n")
+		}
+	}
+
+	state.mu.Lock()
+	state.results[task.Id] = result
+	if result.Closer != nil {
+		state.closers[task.Id] = result.Closer
+	}
+	state.mu.Unlock()
+
+	if result.Err != nil || result.Status == statuses.Error || result.Status == statuses.Cancelled {
+		err := result.Err
+		if err == nil {
+			err = errors.New(result.Message)
+		}
+
+		if task.ContinueOnError && parentCtx.Err() == nil {
+			os.Stdout.WriteString("\x1b[1m" + name + "\x1b[22m failed, continuing: " + err.Error() + "\n")
+			return nil
+		}
+
+		return err
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	envFile := taskEnv.GetString("XTASK_ENV")
+	if len(envFile) > 0 {
+		if err := ws.mergeEnvFile(envFile, state.env); err != nil {
+			return err
+		}
+
+		if isFile(envFile) {
+			os.Remove(envFile)
+		}
+	}
+
+	pathFile := taskEnv.GetString("XTASK_PATH")
+	if len(pathFile) > 0 {
+		if err := mergePathFile(pathFile, state.env); err != nil {
+			return err
+		}
+
+		if isFile(pathFile) {
+			os.Remove(pathFile)
+		}
+	}
+
+	return nil
+}
+
+// release is called when task completed and closes what its needs left
+// running once no other task needs them.
+func (s *runState) release(task types.Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, need := range task.Needs {
+		s.users[need.Name]--
+		if s.users[need.Name] > 0 {
+			continue
+		}
+
+		if closer, ok := s.closers[need.Name]; ok {
+			closer.Close()
+			delete(s.closers, need.Name)
+		}
+	}
+}
+
+// closeAll closes what is still running when the run ends, e.g. a tunnel
+// that no task needs or whose dependents did not run.
+func (s *runState) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, closer := range s.closers {
+		closer.Close()
+		delete(s.closers, id)
+	}
+}
+
+// mergeEnvFile reads the dotenv formatted file written by a task to the
+// path in XTASK_ENV and sets the variables on envMap.
+func (ws *Workflow) mergeEnvFile(envFile string, envMap *types.Env) error {
+	if len(envFile) == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(envFile); err != nil {
+		return nil
+	}
+
+	bytes, err := os.ReadFile(envFile)
+	if err != nil {
+		return errors.New("Failed to read XTASK_ENV file: " + err.Error())
+	}
+
+	if len(bytes) == 0 {
+		return nil
+	}
+
+	opts := &env.ExpandOptions{
+		Get: func(key string) string {
+			val, ok := envMap.Get(key)
+			if ok {
+				return val
 			}
+			return ""
+		},
+		Set: func(key, value string) error {
+			envMap.Set(key, value)
+			return nil
+		},
+		Keys:                envMap.Keys(),
+		ExpandUnixArgs:      true,
+		ExpandWindowsVars:   false,
+		CommandSubstitution: ws.Config.Substitution && !ws.DryRun,
+	}
+	doc2, err := dotenv.Parse(string(bytes))
+	if err != nil {
+		return errors.New("Failed to parse XTASK_ENV file: " + err.Error())
+	}
 
-			if canOpen {
-				bytes, err := os.ReadFile(pathFile)
-				if err != nil {
-					return errors.New("Failed to read XTASK_PATH file: " + err.Error())
-				}
+	for _, node := range doc2.ToArray() {
+		if node.Type == dotenv.VARIABLE_TOKEN {
+			key := ""
+			value := node.Value
+			if node.Key != nil {
+				key = *node.Key
+			}
 
-				if len(bytes) > 0 {
-					content := string(bytes)
-					scanner := bufio.NewScanner(strings.NewReader(content))
-					for scanner.Scan() {
-						line := strings.TrimSpace(scanner.Text())
-						if len(line) > 0 {
-							if _, err := os.Stat(line); err == nil {
-								// LAST IN SHOULD BE FIRST IN PATH
-								envMap.PrependPath(line)
-							}
-						}
+			if strings.HasPrefix("XTASK_", key) {
+				if strings.HasSuffix(key, "_EXE") {
+					value, err := env.ExpandWithOptions(value, opts)
+					if err != nil {
+						return errors.New("Failed to expand environment variable: " + err.Error())
 					}
+
+					envMap.Set(key, value)
 				}
+				continue
 			}
 
-			if isFile(pathFile) {
-				os.Remove(pathFile)
+			value, err := env.ExpandWithOptions(value, opts)
+			if err != nil {
+				return errors.New("Failed to expand environment variable: " + err.Error())
+			}
+			envMap.Set(key, value)
+		}
+	}
+
+	return nil
+}
+
+// mergePathFile reads the file written by a task to the path in XTASK_PATH
+// and prepends each existing directory to the PATH of envMap.
+func mergePathFile(pathFile string, envMap *types.Env) error {
+	if _, err := os.Stat(pathFile); err != nil {
+		return nil
+	}
+
+	bytes, err := os.ReadFile(pathFile)
+	if err != nil {
+		return errors.New("Failed to read XTASK_PATH file: " + err.Error())
+	}
+
+	if len(bytes) == 0 {
+		return nil
+	}
+
+	content := string(bytes)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 {
+			if _, err := os.Stat(line); err == nil {
+				// LAST IN SHOULD BE FIRST IN PATH
+				envMap.PrependPath(line)
 			}
 		}
 	}
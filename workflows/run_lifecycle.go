@@ -134,6 +134,10 @@ func (wf *Workflow) RunLifecycle(target string, app string, contextName string)
 			}
 
 			if nextTaskfile != "" {
+				if wf.DryRun {
+					os.Stdout.WriteString("lifecycle " + target + ": app " + app + " uses " + nextTaskfile + "\n")
+				}
+
 				tf := types.NewXTaskfile()
 				err := tf.DecodeYAMLFile(nextTaskfile)
 				if err != nil {
@@ -148,6 +152,13 @@ func (wf *Workflow) RunLifecycle(target string, app string, contextName string)
 				}
 
 				wf2 := NewWorkflow()
+				wf2.Context = wf.Context
+				wf2.Jobs = wf.Jobs
+				wf2.Force = wf.Force
+				wf2.DryRun = wf.DryRun
+				wf2.Limit = wf.Limit
+				wf2.UpdateImports = wf.UpdateImports
+				wf2.OfflineImports = wf.OfflineImports
 				err = wf2.Load(*tf)
 
 				if err != nil {
@@ -165,6 +176,14 @@ func (wf *Workflow) RunLifecycle(target string, app string, contextName string)
 		return errors.New("no test tasks found")
 	}
 
+	if wf.DryRun {
+		label := target
+		if app != "" {
+			label += ", app " + app
+		}
+		os.Stdout.WriteString("lifecycle " + label + ", context " + contextName + ": " + strings.Join(targets, ", ") + "\n")
+	}
+
 	wf.ContextName = contextName
 	return wf.Run(targets, []string{})
 }
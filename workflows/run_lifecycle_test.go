@@ -0,0 +1,44 @@
+package workflows
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunLifecycleAppKeepsLimit(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "deployed")
+	writeFile(t, filepath.Join(dir, ".xtask", "apps", "api", "xtaskfile"), `
+hosts:
+  web1:
+    host: 10.0.0.1
+  web2:
+    host: 10.0.0.2
+tasks:
+  deploy:
+    uses: bash
+    hosts: [web1]
+    run: touch "`+filepath.ToSlash(marker)+`"
+`, 0644)
+
+	writeFile(t, filepath.Join(dir, "xtaskfile"), `
+tasks:
+  build: echo build
+`, 0644)
+
+	tf := types.NewXTaskfile()
+	assert.NoError(t, tf.DecodeYAMLFile(filepath.Join(dir, "xtaskfile")))
+
+	t.Setenv("XTASK_ENV", filepath.Join(dir, "env"))
+	t.Setenv("XTASK_PATH", filepath.Join(dir, "path"))
+	wf := NewWorkflow()
+	assert.NoError(t, wf.Load(*tf))
+
+	// the app workflow only runs on the hosts of the limit.
+	wf.Limit = "web2"
+	assert.NoError(t, wf.RunLifecycle("deploy", "api", "default"))
+	assert.NoFileExists(t, marker)
+}
@@ -0,0 +1,43 @@
+package workflows
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type countCloser struct {
+	closed int
+}
+
+func (c *countCloser) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestRunStateReleasesClosers(t *testing.T) {
+	tunnel := &countCloser{}
+	unused := &countCloser{}
+	state := &runState{
+		closers: map[string]io.Closer{},
+		users:   map[string]int{"tunnel": 2},
+	}
+	state.closers["tunnel"] = tunnel
+	state.closers["unused"] = unused
+
+	migrate := types.Task{Id: "migrate", Needs: types.Needs{{Name: "tunnel"}}}
+	seed := types.Task{Id: "seed", Needs: types.Needs{{Name: "tunnel"}}}
+
+	state.release(migrate)
+	assert.Equal(t, 0, tunnel.closed)
+
+	state.release(seed)
+	assert.Equal(t, 1, tunnel.closed)
+	assert.Equal(t, 0, unused.closed)
+
+	state.closeAll()
+	assert.Equal(t, 1, tunnel.closed)
+	assert.Equal(t, 1, unused.closed)
+}
@@ -0,0 +1,86 @@
+package workflows
+
+import (
+	"runtime"
+)
+
+type nodeResult struct {
+	node *taskNode
+	err  error
+}
+
+// schedule runs every node in the graph once all of the nodes it needs
+// have completed. At most jobs nodes run at the same time. When more nodes
+// are ready than there are free jobs, the nodes are started in graph order
+// so that a single job runs the tasks in the same order as before.
+//
+// The first error stops any new nodes from being started. Nodes that are
+// already running are allowed to finish before the error is returned.
+func schedule(graph *taskGraph, jobs int, run func(node *taskNode) error) error {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+
+	pending := make(map[*taskNode]int, len(graph.nodes))
+	ready := []*taskNode{}
+	for _, node := range graph.nodes {
+		pending[node] = len(node.needs)
+		if len(node.needs) == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	done := make(chan nodeResult)
+	running := 0
+	var firstErr error
+
+	for len(ready) > 0 || running > 0 {
+		for firstErr == nil && running < jobs && len(ready) > 0 {
+			node := ready[0]
+			ready = ready[1:]
+			running++
+
+			go func(n *taskNode) {
+				done <- nodeResult{node: n, err: run(n)}
+			}(node)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-done
+		running--
+
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+
+		for _, dependent := range result.node.dependents {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = insertByIndex(ready, dependent)
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func insertByIndex(nodes []*taskNode, node *taskNode) []*taskNode {
+	i := len(nodes)
+	for j, n := range nodes {
+		if n.index > node.index {
+			i = j
+			break
+		}
+	}
+
+	nodes = append(nodes, nil)
+	copy(nodes[i+1:], nodes[i:])
+	nodes[i] = node
+	return nodes
+}
@@ -0,0 +1,130 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/types"
+)
+
+// selectHosts returns the sorted aliases of the hosts matched by the
+// selectors of patterns. Each pattern is a comma separated list of terms:
+//
+//	web         the host or group web
+//	web-*       hosts whose alias or one of their groups matches the glob
+//	meta.k=v    hosts whose meta k is v, v may be a glob
+//	all or *    every host
+//	a&b         hosts of a that are also in b
+//	!canary     excludes the hosts of canary
+//
+// The hosts of all plain terms are joined, then reduced to the hosts of
+// every & term and the hosts of ! terms are removed. When there are only
+// & and ! terms, they apply to every host.
+func selectHosts(patterns []string, hosts map[string]types.Host) ([]string, error) {
+	include := []string{}
+	intersect := []string{}
+	exclude := []string{}
+
+	for _, pattern := range patterns {
+		for _, term := range strings.Split(strings.ReplaceAll(pattern, "&", ",&"), ",") {
+			term = strings.TrimSpace(term)
+			switch {
+			case term == "":
+			case strings.HasPrefix(term, "&"):
+				intersect = append(intersect, strings.TrimSpace(term[1:]))
+			case strings.HasPrefix(term, "!"):
+				exclude = append(exclude, strings.TrimSpace(term[1:]))
+			default:
+				include = append(include, term)
+			}
+		}
+	}
+
+	if len(include) == 0 && (len(intersect) > 0 || len(exclude) > 0) {
+		include = append(include, "all")
+	}
+
+	for _, terms := range [][]string{include, intersect, exclude} {
+		for _, term := range terms {
+			if err := validHostTerm(term); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	selected := []string{}
+	for alias, host := range hosts {
+		if !matchAnyHostTerm(include, alias, host) {
+			continue
+		}
+
+		ok := true
+		for _, term := range intersect {
+			if !matchHostTerm(term, alias, host) {
+				ok = false
+				break
+			}
+		}
+
+		if ok && !matchAnyHostTerm(exclude, alias, host) {
+			selected = append(selected, alias)
+		}
+	}
+
+	sort.Strings(selected)
+	return selected, nil
+}
+
+func validHostTerm(term string) error {
+	if term == "" {
+		return errors.New("empty host selector")
+	}
+
+	if strings.HasPrefix(term, "meta.") && !strings.Contains(term, "=") {
+		return errors.New("invalid host selector " + term + ", expected meta.<key>=<value>")
+	}
+
+	if _, err := path.Match(term, ""); err != nil {
+		return errors.New("invalid host selector " + term + ": " + err.Error())
+	}
+
+	return nil
+}
+
+func matchAnyHostTerm(terms []string, alias string, host types.Host) bool {
+	for _, term := range terms {
+		if matchHostTerm(term, alias, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchHostTerm(term string, alias string, host types.Host) bool {
+	if term == "all" || term == "*" {
+		return true
+	}
+
+	if strings.HasPrefix(term, "meta.") {
+		key, pattern, _ := strings.Cut(term[len("meta."):], "=")
+		value, ok := host.Meta[key]
+		if !ok {
+			return false
+		}
+
+		match, _ := path.Match(pattern, fmt.Sprint(value))
+		return match
+	}
+
+	for _, name := range append([]string{alias}, host.Groups...) {
+		if match, _ := path.Match(term, name); match {
+			return true
+		}
+	}
+
+	return false
+}
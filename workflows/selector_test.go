@@ -0,0 +1,50 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectHosts(t *testing.T) {
+	hosts := map[string]types.Host{
+		"web-1":    {Host: "10.0.0.1", Groups: []string{"web", "prod"}, Meta: map[string]interface{}{"region": "eu"}},
+		"web-2":    {Host: "10.0.0.2", Groups: []string{"web", "prod"}, Meta: map[string]interface{}{"region": "us"}},
+		"web-3":    {Host: "10.0.0.3", Groups: []string{"web", "staging"}, Meta: map[string]interface{}{"region": "eu"}},
+		"canary":   {Host: "10.0.0.4", Groups: []string{"web", "prod"}, Meta: map[string]interface{}{"region": "eu-west"}},
+		"db-1":     {Host: "10.0.0.5", Groups: []string{"db", "prod"}, Meta: map[string]interface{}{"replicas": 2}},
+		"bastion":  {Host: "10.0.0.6"},
+		"build-01": {Host: "10.0.0.7", Groups: []string{"ci"}},
+	}
+
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"web"}, []string{"canary", "web-1", "web-2", "web-3"}},
+		{[]string{"web", "db"}, []string{"canary", "db-1", "web-1", "web-2", "web-3"}},
+		{[]string{"web,bastion"}, []string{"bastion", "canary", "web-1", "web-2", "web-3"}},
+		{[]string{"web&prod"}, []string{"canary", "web-1", "web-2"}},
+		{[]string{"web&prod", "!canary"}, []string{"web-1", "web-2"}},
+		{[]string{"web-*"}, []string{"web-1", "web-2", "web-3"}},
+		{[]string{"meta.region=eu"}, []string{"web-1", "web-3"}},
+		{[]string{"meta.region=eu*&prod"}, []string{"canary", "web-1"}},
+		{[]string{"meta.replicas=2"}, []string{"db-1"}},
+		{[]string{"!web"}, []string{"bastion", "build-01", "db-1"}},
+		{[]string{"all", "!prod", "!ci"}, []string{"bastion", "web-3"}},
+		{[]string{"missing"}, []string{}},
+	}
+
+	for _, test := range tests {
+		got, err := selectHosts(test.patterns, hosts)
+		assert.NoError(t, err, test.patterns)
+		assert.Equal(t, test.want, got, test.patterns)
+	}
+
+	_, err := selectHosts([]string{"meta.region"}, hosts)
+	assert.Error(t, err)
+
+	_, err = selectHosts([]string{"web&"}, hosts)
+	assert.Error(t, err)
+}
@@ -0,0 +1,292 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hyprxlabs/xtask/tasks"
+	"github.com/hyprxlabs/xtask/types"
+	"gopkg.in/yaml.v3"
+)
+
+var inputNameInvalid = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+var inputTypes = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true}
+
+// isSharedTaskFile reports whether uses is the path of a shared task file.
+func isSharedTaskFile(uses string) bool {
+	uses = strings.TrimPrefix(uses, "file://")
+	if strings.Contains(uses, "://") {
+		return false
+	}
+
+	return strings.HasSuffix(uses, ".xtask.yaml") || strings.HasSuffix(uses, ".xtask.yml")
+}
+
+// findSharedTask returns the file of the shared task used by uses. A path
+// is resolved from dir, a name is looked up as <name>.xtask.yaml in the
+// tasks dirs. Names of runners are not shared tasks.
+func findSharedTask(uses string, dir string, dirs []string) (string, bool) {
+	uses = strings.TrimSpace(uses)
+	if uses == "" {
+		return "", false
+	}
+
+	if isSharedTaskFile(uses) {
+		file := strings.TrimPrefix(uses, "file://")
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return filepath.Clean(file), true
+	}
+
+	if strings.Contains(uses, "://") {
+		return "", false
+	}
+
+	if _, ok := tasks.Lookup(uses); ok {
+		return "", false
+	}
+
+	for _, d := range dirs {
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(dir, d)
+		}
+
+		for _, ext := range []string{".xtask.yaml", ".xtask.yml"} {
+			file := filepath.Join(d, filepath.FromSlash(uses)+ext)
+			if isFile(file) {
+				return file, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// useSharedTask returns task with the run, uses and inputs of the shared
+// task in file. The desc and help of the task take precedence.
+func useSharedTask(task types.Task, file string) (types.Task, error) {
+	if task.Run != nil && len(strings.TrimSpace(*task.Run)) > 0 {
+		return task, errors.New("task " + task.Id + " uses a shared task and must not set run")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return task, errors.New("failed to read shared task " + file + " for task " + task.Id + ": " + err.Error())
+	}
+
+	var shared types.SharedTask
+	if err := yaml.Unmarshal(data, &shared); err != nil {
+		return task, errors.New("failed to parse shared task " + file + " for task " + task.Id + ": " + err.Error())
+	}
+
+	if shared.Uses != nil {
+		if _, ok := findSharedTask(*shared.Uses, filepath.Dir(file), nil); ok {
+			return task, errors.New("shared task " + file + " must not use another shared task")
+		}
+	}
+
+	if shared.Run == nil && shared.Uses == nil {
+		return task, errors.New("shared task " + file + " has neither run nor uses")
+	}
+
+	ids := map[string]bool{}
+	for _, input := range shared.Inputs {
+		id := inputId(input)
+		if id == "" {
+			return task, errors.New("shared task " + file + " has an input without an id")
+		}
+
+		if ids[id] {
+			return task, errors.New("shared task " + file + " declares input " + id + " twice")
+		}
+		ids[id] = true
+
+		if input.Type != nil && *input.Type != "" && !inputTypes[*input.Type] {
+			return task, errors.New("shared task " + file + ": input " + id + " has unknown type " + *input.Type)
+		}
+
+		if input.Default != nil {
+			if _, err := inputValue(input, *input.Default); err != nil {
+				return task, errors.New("shared task " + file + ": invalid default, " + err.Error())
+			}
+		}
+	}
+
+	task.Run = shared.Run
+	task.Uses = shared.Uses
+	task.Inputs = shared.Inputs
+	if task.Desc == nil {
+		task.Desc = shared.Desc
+	}
+	if task.Help == nil {
+		task.Help = shared.Help
+	}
+
+	return task, nil
+}
+
+func inputId(input types.Input) string {
+	if input.Id != "" {
+		return input.Id
+	}
+
+	if input.Name != nil {
+		return *input.Name
+	}
+
+	return ""
+}
+
+// envVarName returns the env var of an input or param, e.g.
+// INPUT_DOTNET_VERSION for the input dotnet-version.
+func envVarName(prefix string, id string) string {
+	return prefix + strings.Trim(inputNameInvalid.ReplaceAllString(strings.ToUpper(id), "_"), "_")
+}
+
+// resolveInputs returns the values of inputs from with. Missing values are
+// set to their default and values are converted to the type of the input.
+// A value with an expression is not checked as it is only known when the
+// task runs. Keys of with that are neither inputs nor options of the
+// runner are rejected, unless options is nil as the runner does not
+// declare them.
+func resolveInputs(inputs []types.Input, with map[string]interface{}, options []string) (map[string]interface{}, error) {
+	if options != nil {
+		known := map[string]bool{}
+		for _, input := range inputs {
+			known[inputId(input)] = true
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(with)) {
+			if !known[key] && !slices.Contains(options, key) {
+				return nil, errors.New("unknown input " + key)
+			}
+		}
+	}
+
+	values := map[string]interface{}{}
+	for _, input := range inputs {
+		id := inputId(input)
+		value, ok := with[id]
+		if !ok || value == nil {
+			if input.Default != nil {
+				value = *input.Default
+			} else if input.Required != nil && *input.Required {
+				return nil, errors.New("input " + id + " is required")
+			} else {
+				value = ""
+				if input.Type != nil && *input.Type == "boolean" {
+					value = false
+				}
+				values[id] = value
+				continue
+			}
+		}
+
+		if s, ok := value.(string); ok && strings.Contains(s, "${{") {
+			values[id] = s
+			continue
+		}
+
+		converted, err := inputValue(input, value)
+		if err != nil {
+			return nil, err
+		}
+		values[id] = converted
+	}
+
+	return values, nil
+}
+
+// inputValue converts value to the type of input.
+func inputValue(input types.Input, value interface{}) (interface{}, error) {
+	typ := "string"
+	if input.Type != nil && *input.Type != "" {
+		typ = *input.Type
+	}
+
+	id := inputId(input)
+	s := fmt.Sprint(value)
+	switch typ {
+	case "string":
+		if _, ok := value.(map[string]interface{}); ok {
+			return nil, errors.New("input " + id + " must be a string")
+		}
+		if _, ok := value.([]interface{}); ok {
+			return nil, errors.New("input " + id + " must be a string")
+		}
+		return s, nil
+	case "number":
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, errors.New("input " + id + " must be a number, got " + s)
+		}
+		return f, nil
+	case "integer":
+		if v, ok := value.(int); ok {
+			return v, nil
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, errors.New("input " + id + " must be an integer, got " + s)
+		}
+		return i, nil
+	case "boolean":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return nil, errors.New("input " + id + " must be a boolean, got " + s)
+		}
+		return b, nil
+	}
+
+	return nil, errors.New("input " + id + " has unknown type " + typ)
+}
+
+// expandValues replaces `${{ <scope>.<id> }}` expressions with the
+// values, e.g. `${{ inputs.project }}`. Other expressions are left as they
+// are.
+func expandValues(s string, scope string, values map[string]interface{}) (string, error) {
+	if !strings.Contains(s, "${{") {
+		return s, nil
+	}
+
+	var expandErr error
+	out := expressionPattern.ReplaceAllStringFunc(s, func(match string) string {
+		expr := expressionPattern.FindStringSubmatch(match)[1]
+		if !strings.HasPrefix(expr, scope+".") {
+			return match
+		}
+
+		id := strings.TrimPrefix(expr, scope+".")
+		value, ok := values[id]
+		if !ok {
+			expandErr = errors.New("unknown " + strings.TrimSuffix(scope, "s") + " " + id)
+			return match
+		}
+
+		return formatOutput(value)
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return out, nil
+}
@@ -0,0 +1,98 @@
+package workflows
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/xtask/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSharedTasks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tasks", "dotnet-build.xtask.yaml"), `
+desc: Builds a dotnet project
+run: dotnet build ${{ inputs.project }} -c ${{ inputs.configuration }}
+inputs:
+  - id: project
+    required: true
+  - id: configuration
+    default: Release
+`, 0644)
+
+	writeFile(t, filepath.Join(dir, ".xtask", "tasks", "go", "test.xtask.yaml"), `
+uses: bash
+run: go test ./...
+`, 0644)
+
+	writeFile(t, filepath.Join(dir, "xtaskfile"), `
+tasks:
+  build:
+    uses: ./tasks/dotnet-build.xtask.yaml
+    with:
+      project: app.csproj
+  test:
+    uses: go/test
+  lint:
+    uses: bash
+    run: echo lint
+`, 0644)
+
+	tf := types.NewXTaskfile()
+	assert.NoError(t, tf.DecodeYAMLFile(filepath.Join(dir, "xtaskfile")))
+
+	t.Setenv("XTASK_ENV", filepath.Join(dir, "env"))
+	t.Setenv("XTASK_PATH", filepath.Join(dir, "path"))
+	wf := NewWorkflow()
+	assert.NoError(t, wf.Load(*tf))
+
+	build := wf.Tasks["build"]
+	assert.Equal(t, "Builds a dotnet project", *build.Desc)
+	assert.Contains(t, *build.Run, "${{ inputs.project }}")
+	assert.Len(t, build.Inputs, 2)
+
+	assert.Equal(t, "go test ./...", *wf.Tasks["test"].Run)
+	assert.Equal(t, "bash", *wf.Tasks["lint"].Uses)
+}
+
+func TestResolveInputs(t *testing.T) {
+	required := true
+	str := func(s string) *string { return &s }
+	inputs := []types.Input{
+		{Id: "project", Required: &required},
+		{Id: "configuration", Default: str("Release")},
+		{Id: "retries", Type: str("integer"), Default: str("2")},
+		{Id: "verbose", Type: str("boolean")},
+	}
+
+	values, err := resolveInputs(inputs, map[string]interface{}{"project": "app.csproj", "verbose": "true"}, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"project":       "app.csproj",
+		"configuration": "Release",
+		"retries":       2,
+		"verbose":       true,
+	}, values)
+
+	_, err = resolveInputs(inputs, map[string]interface{}{}, []string{})
+	assert.ErrorContains(t, err, "input project is required")
+
+	_, err = resolveInputs(inputs, map[string]interface{}{"project": "a", "retries": "many"}, []string{})
+	assert.ErrorContains(t, err, "input retries must be an integer")
+
+	// a misspelled input is rejected unless it is an option of the runner
+	// or the runner does not declare its options.
+	_, err = resolveInputs(inputs, map[string]interface{}{"project": "a", "projcet": "b"}, []string{})
+	assert.ErrorContains(t, err, "unknown input projcet")
+
+	_, err = resolveInputs(inputs, map[string]interface{}{"project": "a", "shell": "sh"}, []string{"shell"})
+	assert.NoError(t, err)
+
+	_, err = resolveInputs(inputs, map[string]interface{}{"project": "a", "projcet": "b"}, nil)
+	assert.NoError(t, err)
+
+	run, err := expandValues("build ${{ inputs.project }} ${{ tasks.a.outputs.b }}", "inputs", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "build app.csproj ${{ tasks.a.outputs.b }}", run)
+	assert.Equal(t, "INPUT_DOTNET_VERSION", envVarName("INPUT_", "dotnet-version"))
+}
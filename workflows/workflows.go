@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 
+	"github.com/hyprxlabs/xtask/tasks"
 	"github.com/hyprxlabs/xtask/types"
 )
 
@@ -20,9 +21,29 @@ type Workflow struct {
 	Args        []string
 	ContextName string
 	Context     context.Context
+	// Jobs is the maximum number of tasks that may run at the same time.
+	// Zero uses the number of CPUs.
+	Jobs int
+	// Force runs tasks with sources or generates even if they are up to date.
+	Force bool
+	// DryRun prints the tasks that would run and how they are resolved
+	// without running them.
+	DryRun bool
+	// Limit is a host selector that narrows the hosts of every task.
+	Limit string
+	// UpdateImports downloads remote imports again and pins their new
+	// content in xtask.lock.
+	UpdateImports bool
+	// OfflineImports fails instead of downloading remote imports that are
+	// not cached or do not match xtask.lock.
+	OfflineImports bool
+	// Results holds the result of each task that ran during the last call
+	// to Run, keyed by task id.
+	Results     map[string]*tasks.TaskResult
 	cleanupEnv  bool
 	cleanupPath bool
 	parent      *Workflow
+	remote      *remoteImports
 }
 
 func NewWorkflow() *Workflow {
@@ -62,6 +83,7 @@ func NewWorkflow() *Workflow {
 		Hosts:       map[string]types.Host{},
 		Args:        []string{},
 		Context:     context.Background(),
+		Results:     map[string]*tasks.TaskResult{},
 		cleanupEnv:  false,
 		cleanupPath: false,
 	}
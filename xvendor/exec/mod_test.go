@@ -1,6 +1,8 @@
 package exec_test
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -47,3 +49,16 @@ func TestPipeCommand(t *testing.T) {
 	assert.Equal(t, 0, o.Code)
 	assert.Equal(t, "Hello World", strings.TrimSpace(o.Text()))
 }
+
+func TestWhichFirstAbsolutePath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tool")
+	assert.NoError(t, os.WriteFile(file, []byte("#!/bin/sh\n"), 0755))
+
+	path, ok := exec.WhichFirst(file, nil)
+	assert.True(t, ok)
+	assert.Equal(t, file, path)
+
+	_, ok = exec.WhichFirst(dir, nil)
+	assert.False(t, ok)
+}
@@ -60,6 +60,16 @@ func WhichFirst(command string, options *WhichOptions) (string, bool) {
 
 			return path, true
 		}
+
+		if fi.IsDir() {
+			return "", false
+		}
+
+		if options.UseCache {
+			whichCache[name] = command
+		}
+
+		return command, true
 	}
 
 	pathSegments := []string{}